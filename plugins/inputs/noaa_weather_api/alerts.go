@@ -0,0 +1,43 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+)
+
+// AlertCount is the response of the active alerts count endpoint.
+type AlertCount struct {
+	Total   int            `json:"total"`
+	Land    int            `json:"land"`
+	Marine  int            `json:"marine"`
+	Regions map[string]int `json:"regions"`
+}
+
+// gatherAlertCounts reports the number of active alerts in total and per
+// marine region.
+func (n *NOAAWeatherAPI) gatherAlertCounts(ctx context.Context, acc telegraf.Accumulator) error {
+	resp, err := n.request(ctx, n.resolveURL("/alerts/active/count", nil), geoJSONMediaTypes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	count := &AlertCount{}
+	if err := json.NewDecoder(resp.Body).Decode(count); err != nil {
+		return decodeError(err)
+	}
+
+	fields := map[string]interface{}{
+		"total":  count.Total,
+		"land":   count.Land,
+		"marine": count.Marine,
+	}
+	for region, c := range count.Regions {
+		fields[fmt.Sprintf("region_%s", region)] = c
+	}
+	acc.AddFields(n.AlertMeasurement, fields, nil)
+	return nil
+}
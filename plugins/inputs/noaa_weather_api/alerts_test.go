@@ -0,0 +1,72 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleAlertCountResponse = `
+{
+  "total": 395,
+  "land": 324,
+  "marine": 71,
+  "regions": {
+    "AL": 23,
+    "AT": 6,
+    "GL": 21,
+    "GM": 9,
+    "PA": 10,
+    "PI": 2
+  },
+  "areas": {
+    "AK": 26,
+    "FL": 17
+  },
+  "zones": {
+    "FLZ164": 1
+  }
+}
+`
+
+func TestAlertCounts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alerts/active/count" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprint(w, sampleAlertCountResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:            ts.URL,
+		CollectAlertCounts: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	m, ok := acc.Get("noaa_weather_alert_counts")
+	require.True(t, ok)
+	require.Empty(t, m.Tags)
+	require.Equal(t, map[string]interface{}{
+		"total":     int64(395),
+		"land":      int64(324),
+		"marine":    int64(71),
+		"region_AL": int64(23),
+		"region_AT": int64(6),
+		"region_GL": int64(21),
+		"region_GM": int64(9),
+		"region_PA": int64(10),
+		"region_PI": int64(2),
+	}, m.Fields)
+}
@@ -0,0 +1,102 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// batches splits the stations into groups of at most size stations.
+func batches(stations []string, size int) [][]string {
+	var result [][]string
+	for len(stations) > size {
+		result = append(result, stations[:size])
+		stations = stations[size:]
+	}
+	if len(stations) > 0 {
+		result = append(result, stations)
+	}
+	return result
+}
+
+// formatBatchURL returns the URL of the latest observations of several
+// stations at once.
+func (n *NOAAWeatherAPI) formatBatchURL(stations []string) string {
+	v := url.Values{
+		"id":         stations,
+		"require_qc": []string{strconv.FormatBool(n.RequireQC)},
+	}
+	return n.resolveURL("/stations/observations/latest", v)
+}
+
+// gatherBatch requests the latest observations of the stations with a
+// single request and reports them per station like gatherStation does.
+// Stations with an open circuit breaker or a cached observation are not
+// requested.
+func (n *NOAAWeatherAPI) gatherBatch(ctx context.Context, acc telegraf.Accumulator, stations []string) error {
+	requested := make([]string, 0, len(stations))
+	for _, station := range stations {
+		if !n.allowRequest(station) {
+			continue
+		}
+		if n.ObservationCacheTTL > 0 {
+			if status := n.cachedObservation(station); status != nil {
+				if err := n.reportStation(ctx, acc, station, []*Status{status}, 0, nil); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		requested = append(requested, station)
+	}
+	if len(requested) == 0 {
+		return nil
+	}
+
+	start := n.now()
+	collection, err := n.gatherHistoryURL(ctx, n.formatBatchURL(requested))
+	duration := n.now().Sub(start)
+	if errors.Is(err, errBudgetExhausted) {
+		return err
+	}
+	if err != nil {
+		for _, station := range requested {
+			if ctx.Err() != nil {
+				break
+			}
+			_ = n.reportStation(ctx, acc, station, nil, duration, err)
+		}
+		return fmt.Errorf("getting observations of stations %s failed: %w", strings.Join(requested, ", "), err)
+	}
+
+	statuses := make(map[string][]*Status, len(requested))
+	for i := range collection.Features {
+		status := &collection.Features[i].Properties
+		station := strings.ToUpper(lastPathSegment(status.Station))
+		statuses[station] = append(statuses[station], status)
+	}
+
+	for _, station := range requested {
+		observations, ok := statuses[strings.ToUpper(station)]
+		if !ok {
+			err := fmt.Errorf("station %s returned no observation", station)
+			if err := n.reportStation(ctx, acc, station, nil, duration, err); err != nil && ctx.Err() == nil {
+				acc.AddError(&stationFailure{err: err})
+			}
+			continue
+		}
+		sortByTimestamp(observations)
+		if n.ObservationCacheTTL > 0 {
+			n.cacheObservation(station, observations[len(observations)-1])
+		}
+		if err := n.reportStation(ctx, acc, station, observations, duration, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRequests(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stations/observations/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+
+		ids := r.URL.Query()["id"]
+		require.LessOrEqual(t, len(ids), nwaRequestSeveralStationID)
+		features := make([]string, 0, len(ids))
+		for _, id := range ids {
+			properties := strings.Replace(sampleSparseResponse, "stations/KSUA", "stations/"+id, 1)
+			features = append(features, `{"properties": `+properties+`}`)
+		}
+
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprintf(w, `{"features": [%s]}`, strings.Join(features, ","))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	stations := make([]string, 0, 25)
+	for i := 0; i < 25; i++ {
+		stations = append(stations, fmt.Sprintf("K%03d", i))
+	}
+
+	n := &NOAAWeatherAPI{
+		BaseURL:       ts.URL,
+		StationID:     stations,
+		BatchRequests: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	var gathered []string
+	for _, m := range acc.Metrics {
+		gathered = append(gathered, m.Tags["station"])
+	}
+	require.ElementsMatch(t, stations, gathered)
+}
+
+func TestBatchRequestsPerStation(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		// KMIS never reports an observation
+		var features []string
+		for _, id := range r.URL.Query()["id"] {
+			if id == "KMIS" {
+				continue
+			}
+			properties := strings.Replace(sampleSparseResponse, "stations/KSUA", "stations/"+id, 1)
+			features = append(features, `{"properties": `+properties+`}`)
+		}
+
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprintf(w, `{"features": [%s]}`, strings.Join(features, ","))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA", "KMIS"},
+		BatchRequests:       true,
+		EmitUnavailable:     true,
+		CollectStats:        true,
+		FailureThreshold:    1,
+		CircuitCooldown:     config.Duration(time.Hour),
+		ObservationCacheTTL: config.Duration(30 * time.Minute),
+		Log:                 testutil.Logger{},
+	}
+	n.setNow(func() time.Time { return now })
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "KMIS")
+	require.Equal(t, 1, countMeasurement(&acc, "noaa_weather"))
+
+	available := make(map[string]interface{})
+	for _, m := range acc.Metrics {
+		if m.Measurement == "noaa_weather_availability" {
+			available[m.Tags["station"]] = m.Fields["available"]
+		}
+	}
+	// A station missing in the response did not respond with an HTTP error
+	require.Equal(t, map[string]interface{}{"KSUA": int64(1)}, available)
+	require.Equal(t, 2, countMeasurement(&acc, "noaa_weather_internal"))
+
+	// KSUA is served from the cache and the breaker of KMIS is open, so no
+	// request is made
+	acc.ClearMetrics()
+	acc.Errors = nil
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	require.True(t, acc.HasMeasurement("noaa_weather"))
+}
+
+func countMeasurement(acc *testutil.Accumulator, measurement string) int {
+	var count int
+	for _, m := range acc.Metrics {
+		if m.Measurement == measurement {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBatches(t *testing.T) {
+	require.Empty(t, batches(nil, 20))
+	require.Equal(t, [][]string{{"A", "B"}, {"C"}}, batches([]string{"A", "B", "C"}, 2))
+	require.Equal(t, [][]string{{"A", "B"}}, batches([]string{"A", "B"}, 2))
+}
+
+func TestBatchRequestsHistory(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		BatchRequests:   true,
+		HistoryDuration: config.Duration(time.Hour),
+	}
+	require.Error(t, n.Init())
+}
@@ -0,0 +1,63 @@
+package noaa_weather_api
+
+import (
+	"time"
+)
+
+// allowRequest returns false while the circuit breaker of the station is
+// open. Once the cooldown expires a single request is let through to probe
+// the station (half-open).
+func (n *NOAAWeatherAPI) allowRequest(station string) bool {
+	if n.FailureThreshold <= 0 {
+		return true
+	}
+
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	if !state.breakerOpen {
+		return true
+	}
+	now := n.now()
+	if now.Before(state.breakerOpenUntil) {
+		return false
+	}
+	// Block further requests until the probe finished.
+	state.breakerOpenUntil = now.Add(time.Duration(n.CircuitCooldown))
+	return true
+}
+
+// recordResult updates the circuit breaker of the station with the outcome
+// of a request.
+func (n *NOAAWeatherAPI) recordResult(station string, err error) {
+	if n.FailureThreshold <= 0 {
+		return
+	}
+
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	if err == nil {
+		if state.breakerOpen {
+			n.Log.Infof("Station %s recovered, resuming requests", station)
+		}
+		state.breakerOpen = false
+		state.failures = 0
+		return
+	}
+
+	state.failures++
+	if state.breakerOpen {
+		// The probe failed, wait for another cooldown.
+		state.breakerOpenUntil = n.now().Add(time.Duration(n.CircuitCooldown))
+		return
+	}
+	if state.failures >= n.FailureThreshold {
+		n.Log.Warnf("Station %s failed %d times in a row, pausing requests for %s",
+			station, state.failures, time.Duration(n.CircuitCooldown))
+		state.breakerOpen = true
+		state.breakerOpenUntil = n.now().Add(time.Duration(n.CircuitCooldown))
+	}
+}
@@ -0,0 +1,75 @@
+package noaa_weather_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int32
+	var healthy int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := w.Write([]byte(sampleStatusResponse))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:          ts.URL,
+		StationID:        []string{"KSUA"},
+		FailureThreshold: 2,
+		CircuitCooldown:  config.Duration(time.Hour),
+		Log:              testutil.Logger{},
+	}
+	now := time.Date(2021, 11, 7, 18, 50, 0, 0, time.UTC)
+	n.setNow(func() time.Time { return now })
+	require.NoError(t, n.Init())
+
+	// Two failures open the breaker
+	for i := 0; i < 2; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Len(t, acc.Errors, 1)
+	}
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+	require.True(t, n.state["KSUA"].breakerOpen)
+
+	// The station is skipped during the cooldown
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Empty(t, acc.Metrics)
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	// After the cooldown a failing probe keeps the breaker open
+	now = now.Add(time.Hour)
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.EqualValues(t, 3, atomic.LoadInt32(&requests))
+	require.NoError(t, n.Gather(&acc))
+	require.EqualValues(t, 3, atomic.LoadInt32(&requests))
+
+	// A successful probe closes the breaker
+	atomic.StoreInt32(&healthy, 1)
+	now = now.Add(time.Hour)
+	acc = testutil.Accumulator{}
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.False(t, n.state["KSUA"].breakerOpen)
+	require.NoError(t, n.Gather(&acc))
+	require.EqualValues(t, 5, atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,51 @@
+package noaa_weather_api
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errBudgetExhausted is returned instead of making a request once
+// max_requests_per_interval requests were made during the gather.
+var errBudgetExhausted = errors.New("request budget of the interval exhausted")
+
+// takeRequest accounts for a request against the budget of the current
+// gather and returns false if the budget is exhausted.
+func (n *NOAAWeatherAPI) takeRequest() bool {
+	if n.MaxRequestsPerInterval <= 0 {
+		return true
+	}
+	return atomic.AddInt32(&n.requestCount, 1) <= int32(n.MaxRequestsPerInterval)
+}
+
+// budgetSkips records the stations skipped because the request budget was
+// exhausted.
+type budgetSkips struct {
+	sync.Mutex
+	count int
+	first int
+}
+
+// add records count stations skipped from the given position of the gather
+// order on.
+func (s *budgetSkips) add(position int, count int) {
+	s.Lock()
+	defer s.Unlock()
+	if s.count == 0 || position < s.first {
+		s.first = position
+	}
+	s.count += count
+}
+
+// rotateStations returns the stations starting at offset, followed by the
+// ones before it.
+func rotateStations(stations []string, offset int) []string {
+	if len(stations) == 0 {
+		return stations
+	}
+	offset %= len(stations)
+	rotated := make([]string, 0, len(stations))
+	rotated = append(rotated, stations[offset:]...)
+	return append(rotated, stations[:offset]...)
+}
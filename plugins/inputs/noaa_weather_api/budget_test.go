@@ -0,0 +1,97 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxRequestsPerInterval(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, strings.Split(r.URL.Path, "/")[2])
+		mu.Unlock()
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	stations := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		stations = append(stations, fmt.Sprintf("K%03d", i))
+	}
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                ts.URL,
+		StationID:              stations,
+		MaxRequestsPerInterval: 4,
+		Log:                    testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, requested, 4)
+	require.Len(t, acc.Metrics, 4)
+
+	// The budget is renewed every gather
+	requested = nil
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, requested, 4)
+	require.Len(t, acc.Metrics, 4)
+}
+
+func TestBudgetRoundRobin(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, strings.Split(r.URL.Path, "/")[2])
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                ts.URL,
+		StationID:              []string{"KSUA", "KFPR", "KVRB", "KPBI", "KMLB"},
+		MaxParallel:            1,
+		MaxRequestsPerInterval: 2,
+		BudgetRoundRobin:       true,
+		Log:                    testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	for _, expected := range [][]string{
+		{"KSUA", "KFPR"},
+		{"KVRB", "KPBI"},
+		{"KMLB", "KSUA"},
+		{"KFPR", "KVRB"},
+	} {
+		requested = nil
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Empty(t, acc.Errors)
+		require.Equal(t, expected, requested)
+	}
+}
+
+func TestRotateStations(t *testing.T) {
+	stations := []string{"KSUA", "KFPR", "KVRB"}
+	require.Equal(t, []string{"KSUA", "KFPR", "KVRB"}, rotateStations(stations, 0))
+	require.Equal(t, []string{"KVRB", "KSUA", "KFPR"}, rotateStations(stations, 2))
+	require.Equal(t, []string{"KFPR", "KVRB", "KSUA"}, rotateStations(stations, 4))
+	require.Empty(t, rotateStations(nil, 1))
+}
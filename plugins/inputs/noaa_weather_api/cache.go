@@ -0,0 +1,28 @@
+package noaa_weather_api
+
+import (
+	"time"
+)
+
+// cachedObservation returns the observation of the station requested less
+// than observation_cache_ttl ago, or nil if there is none.
+func (n *NOAAWeatherAPI) cachedObservation(station string) *Status {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	if state.cached == nil || n.now().Sub(state.cachedAt) >= time.Duration(n.ObservationCacheTTL) {
+		return nil
+	}
+	return state.cached
+}
+
+// cacheObservation remembers the observation requested for the station.
+func (n *NOAAWeatherAPI) cacheObservation(station string, status *Status) {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	state.cached = status
+	state.cachedAt = n.now()
+}
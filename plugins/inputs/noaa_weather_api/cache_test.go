@@ -0,0 +1,50 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObservationCacheTTL(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA"},
+		ObservationCacheTTL: config.Duration(30 * time.Minute),
+	}
+	n.setNow(func() time.Time { return now })
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	now = now.Add(10 * time.Minute)
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// The cached observation is reported with its original timestamp
+	require.Len(t, acc.Metrics, 2)
+	require.Equal(t, acc.Metrics[0].Fields, acc.Metrics[1].Fields)
+	require.Equal(t, time.Date(2021, 11, 7, 18, 50, 0, 0, time.UTC), acc.Metrics[1].Time.UTC())
+
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, n.Gather(&acc))
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
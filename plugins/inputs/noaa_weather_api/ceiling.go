@@ -0,0 +1,45 @@
+package noaa_weather_api
+
+// CloudLayer is a layer of clouds reported with an observation.
+type CloudLayer struct {
+	Base   ApiValue `json:"base"`
+	Amount string   `json:"amount"`
+}
+
+// ceiling returns the base of the lowest broken or overcast cloud layer,
+// the ceiling in aviation terms. Layers without a base are skipped.
+func ceiling(layers []CloudLayer) (ApiValue, bool) {
+	var lowest ApiValue
+	found := false
+	for _, layer := range layers {
+		if layer.Amount != "BKN" && layer.Amount != "OVC" {
+			continue
+		}
+		if layer.Base.Value == nil {
+			continue
+		}
+		if !found || *layer.Base.Value < *lowest.Value {
+			lowest = layer.Base
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// addCeiling adds the ceiling of the observation to the fields, in feet for
+// imperial and in meters for metric units.
+func (n *NOAAWeatherAPI) addCeiling(fields map[string]interface{}, status *Status) {
+	base, ok := ceiling(status.CloudLayers)
+	if !ok {
+		return
+	}
+
+	value := *base.Value
+	if n.ServerSideUnits == "" && n.Units == "imperial" && base.UnitCode == "wmoUnit:m" {
+		value = convertMeters(value, "ft")
+	}
+	fields["ceiling"] = value
+	if n.Units == "none" && base.UnitCode != "" {
+		fields["ceiling_unit"] = base.UnitCode
+	}
+}
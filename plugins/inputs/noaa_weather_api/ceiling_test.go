@@ -0,0 +1,69 @@
+package noaa_weather_api
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCeiling(t *testing.T) {
+	layers := []CloudLayer{
+		{Base: apiValue("wmoUnit:m", 610), Amount: "FEW"},
+		{Base: apiValue("wmoUnit:m", 2290), Amount: "OVC"},
+		{Amount: "BKN"},
+		{Base: apiValue("wmoUnit:m", 1520), Amount: "BKN"},
+		{Base: apiValue("wmoUnit:m", 1220), Amount: "SCT"},
+	}
+
+	tests := []struct {
+		units    string
+		layers   []CloudLayer
+		expected map[string]interface{}
+	}{
+		{
+			units:    "metric",
+			layers:   layers,
+			expected: map[string]interface{}{"ceiling": float64(1520)},
+		},
+		{
+			units:    "imperial",
+			layers:   layers,
+			expected: map[string]interface{}{"ceiling": float64(4986.876640419947)},
+		},
+		{
+			units:    "none",
+			layers:   layers,
+			expected: map[string]interface{}{"ceiling": float64(1520), "ceiling_unit": "wmoUnit:m"},
+		},
+		{
+			units:    "metric",
+			layers:   layers[:1],
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				Units: tt.units,
+			}
+			require.NoError(t, n.Init())
+
+			status := &Status{
+				Timestamp:   "2021-11-07T18:50:00+00:00",
+				Temperature: apiValue("wmoUnit:degC", 21),
+				CloudLayers: tt.layers,
+			}
+			var acc testutil.Accumulator
+			n.gatherWeather(&acc, "KSUA", status)
+			require.Empty(t, acc.Errors)
+
+			m, ok := acc.Get("noaa_weather")
+			require.True(t, ok)
+			delete(m.Fields, "temperature")
+			delete(m.Fields, "temperature_unit")
+			require.Equal(t, tt.expected, m.Fields)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// initCompareStations validates the station pairs of compare_stations.
+func (n *NOAAWeatherAPI) initCompareStations() error {
+	for _, pair := range n.CompareStations {
+		if len(pair) != 2 {
+			return fmt.Errorf("compare_stations must contain pairs of stations: %v", pair)
+		}
+		for _, station := range pair {
+			if !stationIDPattern.MatchString(station) {
+				return fmt.Errorf("invalid station in compare_stations: %q", station)
+			}
+		}
+	}
+	return nil
+}
+
+// recordComparedValues remembers the observation values of the station
+// reported during the current gather if the station is compared.
+func (n *NOAAWeatherAPI) recordComparedValues(station string, fields map[string]interface{}) {
+	if len(n.CompareStations) == 0 {
+		return
+	}
+
+	values := make(map[string]float64)
+	for name := range (&Status{}).values() {
+		if v, ok := fields[name].(float64); ok {
+			values[name] = v
+		}
+	}
+
+	n.comparedLock.Lock()
+	defer n.comparedLock.Unlock()
+	n.compared[strings.ToUpper(station)] = values
+}
+
+// addDeltas reports the difference of the values of the first and the
+// second station of each pair of compare_stations. Values null at either
+// station are skipped.
+func (n *NOAAWeatherAPI) addDeltas(acc telegraf.Accumulator) {
+	n.comparedLock.Lock()
+	defer n.comparedLock.Unlock()
+
+	for _, pair := range n.CompareStations {
+		a, aok := n.compared[strings.ToUpper(pair[0])]
+		b, bok := n.compared[strings.ToUpper(pair[1])]
+		if !aok || !bok {
+			continue
+		}
+
+		names := make([]string, 0, len(a))
+		for name := range a {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fields := make(map[string]interface{})
+		for _, name := range names {
+			if vb, ok := b[name]; ok {
+				fields[name+"_delta"] = a[name] - vb
+			}
+		}
+		tags := map[string]string{"station_a": pair[0], "station_b": pair[1]}
+		acc.AddFields("noaa_weather_delta", fields, tags, n.now())
+	}
+}
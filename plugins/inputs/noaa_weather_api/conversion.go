@@ -0,0 +1,86 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+)
+
+// converter converts a value of an API unit code into a target unit.
+type converter struct {
+	target  string
+	convert func(float64) float64
+}
+
+func identity(v float64) float64 {
+	return v
+}
+
+// unitConversions maps the unit codes of the API to their conversion per
+// unit system.
+var unitConversions = map[string]map[string]converter{
+	"imperial": {
+		"wmoUnit:degC":   {target: "F", convert: func(v float64) float64 { return v*9.0/5.0 + 32 }},
+		"wmoUnit:km_h-1": {target: "mph", convert: func(v float64) float64 { return v / 1.609 }},
+		"wmoUnit:m":      {target: "mi", convert: func(v float64) float64 { return v / 1609.0 }},
+	},
+	"metric": {
+		"wmoUnit:degC":   {target: "C", convert: identity},
+		"wmoUnit:km_h-1": {target: "km/h", convert: identity},
+		"wmoUnit:m":      {target: "m", convert: identity},
+	},
+}
+
+// UnitOverride converts values of the unit code using a linear conversion
+// instead of the built-in one.
+type UnitOverride struct {
+	UnitCode string  `toml:"unit_code"`
+	Target   string  `toml:"target"`
+	Scale    float64 `toml:"scale"`
+	Offset   float64 `toml:"offset"`
+}
+
+// initConversions sets up the conversions of the configured unit system,
+// including the overrides.
+func (n *NOAAWeatherAPI) initConversions() error {
+	n.conversions = make(map[string]converter)
+	for code, c := range unitConversions[n.Units] {
+		n.conversions[code] = c
+	}
+
+	n.overridden = make(map[string]bool, len(n.UnitOverrides))
+	for _, o := range n.UnitOverrides {
+		if o.UnitCode == "" {
+			return fmt.Errorf("unit_code of unit_overrides must not be empty")
+		}
+		if o.Scale == 0 {
+			return fmt.Errorf("scale of unit_overrides for %s must not be zero", o.UnitCode)
+		}
+		target := o.Target
+		if target == "" {
+			target = o.UnitCode
+		}
+		scale, offset := o.Scale, o.Offset
+		n.conversions[o.UnitCode] = converter{
+			target:  target,
+			convert: func(v float64) float64 { return v*scale + offset },
+		}
+		n.overridden[o.UnitCode] = true
+	}
+	return nil
+}
+
+// UnitConversion converts a value into the configured unit system. Null
+// values are returned as zero and should be skipped by the caller.
+func (n *NOAAWeatherAPI) UnitConversion(value ApiValue) float64 {
+	if value.Value == nil {
+		return 0
+	}
+	v := *value.Value
+	if n.Units == "none" {
+		return v
+	}
+
+	if c, ok := n.conversions[value.UnitCode]; ok {
+		return c.convert(v)
+	}
+	return v
+}
@@ -0,0 +1,108 @@
+package noaa_weather_api
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitConversionTable(t *testing.T) {
+	tests := []struct {
+		units    string
+		value    ApiValue
+		expected float64
+	}{
+		{units: "imperial", value: apiValue("wmoUnit:degC", 100), expected: 212},
+		{units: "imperial", value: apiValue("wmoUnit:km_h-1", 16.09), expected: 10},
+		{units: "imperial", value: apiValue("wmoUnit:m", 1609), expected: 1},
+		{units: "imperial", value: apiValue("wmoUnit:Pa", 101520), expected: 101520},
+		{units: "metric", value: apiValue("wmoUnit:degC", 21), expected: 21},
+		{units: "none", value: apiValue("wmoUnit:degC", 21), expected: 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units+"/"+tt.value.UnitCode, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				Units: tt.units,
+			}
+			require.NoError(t, n.Init())
+			require.InDelta(t, tt.expected, n.UnitConversion(tt.value), 1e-9)
+		})
+	}
+
+	// Values decoded from null must not panic
+	n := &NOAAWeatherAPI{}
+	require.NoError(t, n.Init())
+	require.Zero(t, n.UnitConversion(ApiValue{UnitCode: "wmoUnit:degC"}))
+}
+
+func TestUnitOverrides(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		Units:     "imperial",
+		UnitOverrides: []UnitOverride{
+			{UnitCode: "wmoUnit:Pa", Target: "inHg", Scale: 0.0002953},
+			{UnitCode: "wmoUnit:degC", Target: "K", Scale: 1, Offset: 273.15},
+		},
+		EmitUnitMetadata: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	pressure, ok := acc.FloatField("noaa_weather", "pressure")
+	require.True(t, ok)
+	require.InDelta(t, 29.97886, pressure, 1e-5)
+	temperature, ok := acc.FloatField("noaa_weather", "temperature")
+	require.True(t, ok)
+	require.InDelta(t, 294.15, temperature, 1e-9)
+	dewpoint, ok := acc.FloatField("noaa_weather", "dewpoint")
+	require.True(t, ok)
+	require.InDelta(t, 284.15, dewpoint, 1e-9)
+
+	m, ok := acc.Get("noaa_weather_units")
+	require.True(t, ok)
+	require.Equal(t, "inHg", m.Fields["pressure_target"])
+	require.Equal(t, "K", m.Fields["temperature_target"])
+
+	n = &NOAAWeatherAPI{
+		UnitOverrides: []UnitOverride{{UnitCode: "wmoUnit:Pa"}},
+	}
+	require.Error(t, n.Init())
+}
+
+func TestIncludeRawValues(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:          ts.URL,
+		StationID:        []string{"KSUA"},
+		IncludeRawValues: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+
+	fields := acc.Metrics[0].Fields
+	require.InDelta(t, 69.8, fields["temperature"], 1e-9)
+	require.Equal(t, 21.0, fields["temperature_raw"])
+	require.Equal(t, "wmoUnit:degC", fields["temperature_raw_unit"])
+	require.InDelta(t, 22.32, fields["wind_speed_raw"], 1e-9)
+	require.Equal(t, "wmoUnit:km_h-1", fields["wind_speed_raw_unit"])
+	require.NotContains(t, fields, "temperature_unit")
+}
@@ -0,0 +1,164 @@
+package noaa_weather_api
+
+import (
+	"math"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Temperature thresholds in °F from which on the NWS computes the heat
+// index or below which it computes the wind chill.
+const (
+	heatIndexMinTemperature = 80.0
+	windChillMaxTemperature = 50.0
+	windChillMinWindSpeed   = 3.0
+)
+
+// addDerivedTemperatures adds the heat index and wind chill of the
+// observation to the fields. Values missing in the observation are computed
+// from temperature, humidity and wind speed if compute_derived is set; the
+// return value reports whether any value was computed.
+func (n *NOAAWeatherAPI) addDerivedTemperatures(acc telegraf.Accumulator, fields map[string]interface{}, status *Status) bool {
+	derived := false
+
+	if status.HeatIndex.Value != nil {
+		n.addValue(acc, fields, "heat_index", status.HeatIndex)
+	} else if n.ComputeDerived {
+		if value, ok := computeHeatIndex(status.Temperature, status.Humidity); ok {
+			n.addValue(acc, fields, "heat_index", value)
+			derived = true
+		}
+	}
+
+	if status.WindChill.Value != nil {
+		n.addValue(acc, fields, "wind_chill", status.WindChill)
+	} else if n.ComputeDerived {
+		if value, ok := computeWindChill(status.Temperature, status.WindSpeed); ok {
+			n.addValue(acc, fields, "wind_chill", value)
+			derived = true
+		}
+	}
+
+	return derived
+}
+
+// computeHeatIndex computes the heat index using the Rothfusz regression
+// including the NWS adjustments for low and high humidity.
+func computeHeatIndex(temperature, humidity ApiValue) (ApiValue, bool) {
+	if temperature.Value == nil || temperature.UnitCode != "wmoUnit:degC" ||
+		humidity.Value == nil || humidity.UnitCode != "wmoUnit:percent" {
+		return ApiValue{}, false
+	}
+
+	t := celsiusToFahrenheit(*temperature.Value)
+	rh := *humidity.Value
+	if t < heatIndexMinTemperature {
+		return ApiValue{}, false
+	}
+
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+
+	return derivedValue(hi), true
+}
+
+// computeWindChill computes the wind chill using the NWS formula.
+func computeWindChill(temperature, windSpeed ApiValue) (ApiValue, bool) {
+	if temperature.Value == nil || temperature.UnitCode != "wmoUnit:degC" ||
+		windSpeed.Value == nil || windSpeed.UnitCode != "wmoUnit:km_h-1" {
+		return ApiValue{}, false
+	}
+
+	t := celsiusToFahrenheit(*temperature.Value)
+	v := *windSpeed.Value / 1.609
+	if t > windChillMaxTemperature || v < windChillMinWindSpeed {
+		return ApiValue{}, false
+	}
+
+	f := math.Pow(v, 0.16)
+	wc := 35.74 + 0.6215*t - 35.75*f + 0.4275*t*f
+
+	return derivedValue(wc), true
+}
+
+// computeAbsoluteHumidity computes the absolute humidity in g/m³ from the
+// temperature and relative humidity using the Magnus formula for the
+// saturation vapor pressure.
+func computeAbsoluteHumidity(temperature, humidity ApiValue) (float64, bool) {
+	if temperature.Value == nil || humidity.Value == nil || humidity.UnitCode != "wmoUnit:percent" {
+		return 0, false
+	}
+
+	var t float64
+	switch temperature.UnitCode {
+	case "wmoUnit:degC":
+		t = *temperature.Value
+	case "wmoUnit:degF":
+		t = (*temperature.Value - 32) * 5.0 / 9.0
+	default:
+		return 0, false
+	}
+
+	// Saturation vapor pressure in hPa
+	es := 6.112 * math.Exp(17.67*t/(t+243.5))
+	return es * *humidity.Value * 2.1674 / (273.15 + t), true
+}
+
+// addSeaLevelPressure adds the sea-level pressure of the observation to the
+// fields, reducing the station pressure to sea level if the API reported
+// none.
+func (n *NOAAWeatherAPI) addSeaLevelPressure(acc telegraf.Accumulator, fields map[string]interface{}, station string, status *Status) {
+	if status.SeaLevelPressure.Value != nil {
+		n.addValue(acc, fields, "sea_level_pressure", status.SeaLevelPressure)
+		return
+	}
+
+	meta := n.cachedMetadata(station)
+	if meta == nil {
+		return
+	}
+	if value, ok := computeSeaLevelPressure(status.BarometricPressure, status.Temperature, meta.Elevation); ok {
+		n.addValue(acc, fields, "sea_level_pressure", value)
+	}
+}
+
+// computeSeaLevelPressure reduces the station pressure to sea level using
+// the barometric formula with the standard temperature lapse rate.
+func computeSeaLevelPressure(pressure, temperature, elevation ApiValue) (ApiValue, bool) {
+	if pressure.Value == nil || pressure.UnitCode != "wmoUnit:Pa" ||
+		elevation.Value == nil || elevation.UnitCode != "wmoUnit:m" || temperature.Value == nil {
+		return ApiValue{}, false
+	}
+
+	var t float64
+	switch temperature.UnitCode {
+	case "wmoUnit:degC":
+		t = *temperature.Value
+	case "wmoUnit:degF":
+		t = (*temperature.Value - 32) * 5.0 / 9.0
+	default:
+		return ApiValue{}, false
+	}
+
+	const lapseRate = 0.0065 // K/m
+	h := *elevation.Value
+	p := *pressure.Value * math.Pow(1-lapseRate*h/(t+lapseRate*h+273.15), -5.257)
+	return ApiValue{UnitCode: "wmoUnit:Pa", Value: &p}, true
+}
+
+func celsiusToFahrenheit(c float64) float64 {
+	return c*9.0/5.0 + 32
+}
+
+// derivedValue returns the computed temperature in °F as API value in °C.
+func derivedValue(f float64) ApiValue {
+	c := (f - 32) * 5.0 / 9.0
+	return ApiValue{UnitCode: "wmoUnit:degC", Value: &c}
+}
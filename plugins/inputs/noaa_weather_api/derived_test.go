@@ -0,0 +1,219 @@
+package noaa_weather_api
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func apiValue(unit string, v float64) ApiValue {
+	return ApiValue{UnitCode: unit, Value: &v}
+}
+
+func TestComputeDerived(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   Status
+		field    string
+		expected float64
+		derived  bool
+	}{
+		{
+			name: "heat index",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 32.22222222222222),
+				Humidity:    apiValue("wmoUnit:percent", 70),
+			},
+			field:    "heat_index",
+			expected: 105.9220206,
+			derived:  true,
+		},
+		{
+			name: "wind chill",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 5),
+				WindSpeed:   apiValue("wmoUnit:km_h-1", 32.18),
+			},
+			field:    "wind_chill",
+			expected: 31.7926861,
+			derived:  true,
+		},
+		{
+			name: "reported by the API",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 5),
+				WindSpeed:   apiValue("wmoUnit:km_h-1", 32.18),
+				WindChill:   apiValue("wmoUnit:degC", 0),
+			},
+			field:    "wind_chill",
+			expected: 32,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				Units:          "imperial",
+				ComputeDerived: true,
+			}
+			require.NoError(t, n.Init())
+
+			tt.status.Timestamp = "2021-11-07T18:50:00+00:00"
+			var acc testutil.Accumulator
+			n.gatherWeather(&acc, "KSUA", &tt.status)
+			require.Empty(t, acc.Errors)
+
+			value, ok := acc.FloatField("noaa_weather", tt.field)
+			require.True(t, ok)
+			require.InDelta(t, tt.expected, value, 1e-6)
+			require.Equal(t, tt.derived, acc.HasTag("noaa_weather", "derived"))
+		})
+	}
+}
+
+func TestComputeDerivedThresholds(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		Units:          "imperial",
+		ComputeDerived: true,
+	}
+	require.NoError(t, n.Init())
+
+	// Neither hot enough for the heat index nor cold enough for wind chill
+	status := &Status{
+		Timestamp:   "2021-11-07T18:50:00+00:00",
+		Temperature: apiValue("wmoUnit:degC", 21),
+		Humidity:    apiValue("wmoUnit:percent", 70),
+		WindSpeed:   apiValue("wmoUnit:km_h-1", 32.18),
+	}
+	var acc testutil.Accumulator
+	n.gatherWeather(&acc, "KSUA", status)
+	require.Empty(t, acc.Errors)
+	require.False(t, acc.HasField("noaa_weather", "heat_index"))
+	require.False(t, acc.HasField("noaa_weather", "wind_chill"))
+	require.False(t, acc.HasTag("noaa_weather", "derived"))
+}
+
+func TestComputeAbsoluteHumidity(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   Status
+		expected float64
+		ok       bool
+	}{
+		{
+			name: "room climate",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 20),
+				Humidity:    apiValue("wmoUnit:percent", 50),
+			},
+			expected: 8.64,
+			ok:       true,
+		},
+		{
+			name: "tropical",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 30),
+				Humidity:    apiValue("wmoUnit:percent", 80),
+			},
+			expected: 24.28,
+			ok:       true,
+		},
+		{
+			name: "humidity missing",
+			status: Status{
+				Temperature: apiValue("wmoUnit:degC", 20),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				Units:                   "metric",
+				ComputeAbsoluteHumidity: true,
+			}
+			require.NoError(t, n.Init())
+
+			tt.status.Timestamp = "2021-11-07T18:50:00+00:00"
+			var acc testutil.Accumulator
+			n.gatherWeather(&acc, "KSUA", &tt.status)
+			require.Empty(t, acc.Errors)
+
+			value, ok := acc.FloatField("noaa_weather", "absolute_humidity")
+			require.Equal(t, tt.ok, ok)
+			require.InDelta(t, tt.expected, value, 0.01)
+		})
+	}
+}
+
+func TestComputeSeaLevelPressure(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    Status
+		elevation ApiValue
+		expected  float64
+		ok        bool
+	}{
+		{
+			name: "reduced",
+			status: Status{
+				BarometricPressure: apiValue("wmoUnit:Pa", 95000),
+				Temperature:        apiValue("wmoUnit:degC", 15),
+			},
+			elevation: apiValue("wmoUnit:m", 500),
+			expected:  100769.72,
+			ok:        true,
+		},
+		{
+			name: "reported by the API",
+			status: Status{
+				BarometricPressure: apiValue("wmoUnit:Pa", 95000),
+				Temperature:        apiValue("wmoUnit:degC", 15),
+				SeaLevelPressure:   apiValue("wmoUnit:Pa", 101000),
+			},
+			elevation: apiValue("wmoUnit:m", 500),
+			expected:  101000,
+			ok:        true,
+		},
+		{
+			name: "temperature missing",
+			status: Status{
+				BarometricPressure: apiValue("wmoUnit:Pa", 95000),
+				Humidity:           apiValue("wmoUnit:percent", 50),
+			},
+			elevation: apiValue("wmoUnit:m", 500),
+		},
+		{
+			name: "elevation missing",
+			status: Status{
+				BarometricPressure: apiValue("wmoUnit:Pa", 95000),
+				Temperature:        apiValue("wmoUnit:degC", 15),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				Units:                   "metric",
+				IncludeStationMetadata:  true,
+				ComputeSeaLevelPressure: true,
+			}
+			require.NoError(t, n.Init())
+			n.metadata["KSUA"] = &StationMetadata{Elevation: tt.elevation}
+
+			tt.status.Timestamp = "2021-11-07T18:50:00+00:00"
+			var acc testutil.Accumulator
+			n.gatherWeather(&acc, "KSUA", &tt.status)
+			require.Empty(t, acc.Errors)
+
+			value, ok := acc.FloatField("noaa_weather", "sea_level_pressure")
+			require.Equal(t, tt.ok, ok)
+			require.InDelta(t, tt.expected, value, 0.01)
+		})
+	}
+
+	n := &NOAAWeatherAPI{ComputeSeaLevelPressure: true}
+	require.Error(t, n.Init())
+}
@@ -0,0 +1,66 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// initFallbackURLs parses the fallback_urls tried when a request against
+// base_url fails.
+func (n *NOAAWeatherAPI) initFallbackURLs() error {
+	n.baseURLs = []*url.URL{n.baseParsedURL}
+	for _, addr := range n.FallbackURLs {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return fmt.Errorf("invalid fallback_urls entry %q: %s", addr, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid fallback_urls entry %q: scheme and host required", addr)
+		}
+		n.baseURLs = append(n.baseURLs, u)
+	}
+	return nil
+}
+
+// withFailover calls fn with the address resolved against each base URL in
+// turn until it succeeds, starting with the base URL that succeeded last.
+// The error of the last attempt is returned if all fail.
+func (n *NOAAWeatherAPI) withFailover(ctx context.Context, addr string, fn func(addr string) error) error {
+	if len(n.baseURLs) < 2 {
+		return fn(addr)
+	}
+
+	start := int(atomic.LoadInt32(&n.lastGoodBase))
+	var err error
+	for i := range n.baseURLs {
+		idx := (start + i) % len(n.baseURLs)
+		if err = fn(n.rebaseURL(addr, n.baseURLs[idx])); err == nil {
+			atomic.StoreInt32(&n.lastGoodBase, int32(idx))
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if i < len(n.baseURLs)-1 {
+			n.Log.Debugf("Request against %s failed, trying next base URL: %s", n.baseURLs[idx].Host, err)
+		}
+	}
+	return err
+}
+
+// rebaseURL replaces the base URL of an address resolved by resolveURL.
+func (n *NOAAWeatherAPI) rebaseURL(addr string, base *url.URL) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return addr
+	}
+	rebased := *base
+	rebased.Path = strings.TrimSuffix(base.Path, "/") +
+		strings.TrimPrefix(u.Path, strings.TrimSuffix(n.baseParsedURL.Path, "/"))
+	rebased.RawPath = ""
+	rebased.RawQuery = u.RawQuery
+	return rebased.String()
+}
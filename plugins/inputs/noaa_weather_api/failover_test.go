@@ -0,0 +1,54 @@
+package noaa_weather_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackURLs(t *testing.T) {
+	var primaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryRequests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	mirror := newStationServer(t, map[string]string{
+		"/api/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer mirror.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:      primary.URL,
+		FallbackURLs: []string{mirror.URL + "/api"},
+		StationID:    []string{"KSUA"},
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, 69.8, acc.Metrics[0].Fields["temperature"])
+	require.Equal(t, int32(1), atomic.LoadInt32(&primaryRequests))
+
+	// The mirror that succeeded is preferred afterwards
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+	require.Equal(t, int32(1), atomic.LoadInt32(&primaryRequests))
+
+	n = &NOAAWeatherAPI{
+		BaseURL:      primary.URL,
+		FallbackURLs: []string{"mirror.example.com"},
+	}
+	err := n.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fallback_urls")
+}
@@ -0,0 +1,207 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// gridpoint identifies a cell of the forecast grid of an NWS office.
+type gridpoint struct {
+	Office string
+	X      int
+	Y      int
+}
+
+func (g gridpoint) String() string {
+	return fmt.Sprintf("%s/%d,%d", g.Office, g.X, g.Y)
+}
+
+// tags returns the tags identifying the gridpoint of a forecast.
+func (g gridpoint) tags() map[string]string {
+	return map[string]string{
+		"office": g.Office,
+		"grid_x": strconv.Itoa(g.X),
+		"grid_y": strconv.Itoa(g.Y),
+	}
+}
+
+// parseGridpoint parses a gridpoint in the "OFFICE/X,Y" notation used by
+// the gridpoints endpoints.
+func parseGridpoint(s string) (gridpoint, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return gridpoint{}, fmt.Errorf("invalid gridpoint %q, expected OFFICE/X,Y", s)
+	}
+	coordinates := strings.SplitN(parts[1], ",", 2)
+	if len(coordinates) != 2 {
+		return gridpoint{}, fmt.Errorf("invalid gridpoint %q, expected OFFICE/X,Y", s)
+	}
+	x, err := strconv.Atoi(coordinates[0])
+	if err != nil {
+		return gridpoint{}, fmt.Errorf("invalid x coordinate of gridpoint %q: %s", s, err)
+	}
+	y, err := strconv.Atoi(coordinates[1])
+	if err != nil {
+		return gridpoint{}, fmt.Errorf("invalid y coordinate of gridpoint %q: %s", s, err)
+	}
+	return gridpoint{Office: strings.ToUpper(parts[0]), X: x, Y: y}, nil
+}
+
+// Point is the response of the points endpoint resolving a location to its
+// forecast gridpoint.
+type Point struct {
+	Properties struct {
+		GridID string `json:"gridId"`
+		GridX  int    `json:"gridX"`
+		GridY  int    `json:"gridY"`
+	} `json:"properties"`
+}
+
+// Forecast is the response of the gridpoint forecast endpoint.
+type Forecast struct {
+	Properties struct {
+		Periods []ForecastPeriod `json:"periods"`
+	} `json:"properties"`
+}
+
+// ForecastPeriod is a single period, usually half a day, of a forecast.
+type ForecastPeriod struct {
+	Number          int     `json:"number"`
+	Name            string  `json:"name"`
+	StartTime       string  `json:"startTime"`
+	EndTime         string  `json:"endTime"`
+	IsDaytime       bool    `json:"isDaytime"`
+	Temperature     float64 `json:"temperature"`
+	TemperatureUnit string  `json:"temperatureUnit"`
+	ShortForecast   string  `json:"shortForecast"`
+
+	DetailedForecast           string   `json:"detailedForecast"`
+	ProbabilityOfPrecipitation ApiValue `json:"probabilityOfPrecipitation"`
+	RelativeHumidity           ApiValue `json:"relativeHumidity"`
+	Dewpoint                   ApiValue `json:"dewpoint"`
+}
+
+// initGridpoints parses the configured gridpoints and resolves the
+// configured forecast points to their gridpoints.
+func (n *NOAAWeatherAPI) initGridpoints() error {
+	n.gridpoints = make([]gridpoint, 0, len(n.ForecastGridpoints)+len(n.ForecastPoints))
+	for _, s := range n.ForecastGridpoints {
+		g, err := parseGridpoint(s)
+		if err != nil {
+			return err
+		}
+		n.gridpoints = append(n.gridpoints, g)
+	}
+	for _, point := range n.ForecastPoints {
+		g, err := n.resolvePoint(point)
+		if err != nil {
+			return fmt.Errorf("resolving forecast point %s failed: %s", point, err)
+		}
+		n.gridpoints = append(n.gridpoints, g)
+	}
+	return nil
+}
+
+// resolvePoint looks up the gridpoint of a "latitude,longitude" location.
+func (n *NOAAWeatherAPI) resolvePoint(point string) (gridpoint, error) {
+	resp, err := n.request(context.Background(), n.resolveURL("/points/"+strings.ReplaceAll(point, " ", ""), nil), geoJSONMediaTypes)
+	if err != nil {
+		return gridpoint{}, err
+	}
+	defer resp.Body.Close()
+
+	p := &Point{}
+	if err := json.NewDecoder(resp.Body).Decode(p); err != nil {
+		return gridpoint{}, fmt.Errorf("error while decoding JSON response: %s", err)
+	}
+	if p.Properties.GridID == "" {
+		return gridpoint{}, fmt.Errorf("no gridpoint returned")
+	}
+	return gridpoint{Office: p.Properties.GridID, X: p.Properties.GridX, Y: p.Properties.GridY}, nil
+}
+
+func (n *NOAAWeatherAPI) gatherForecast(ctx context.Context, acc telegraf.Accumulator, g gridpoint) error {
+	addr := n.resolveURL(fmt.Sprintf("/gridpoints/%s/%d,%d/forecast", g.Office, g.X, g.Y), nil)
+	resp, err := n.request(ctx, addr, geoJSONMediaTypes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	forecast, err := decodeForecast(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, period := range forecast.Properties.Periods {
+		tm, err := parseTimestamp(period.StartTime)
+		if err != nil {
+			acc.AddError(fmt.Errorf("forecast %s returned invalid start time: %s", g, err))
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"period":         period.Number,
+			"temperature":    n.forecastTemperature(period),
+			"short_forecast": period.ShortForecast,
+		}
+		if n.Units == "none" {
+			fields["temperature_unit"] = period.TemperatureUnit
+		}
+		if period.DetailedForecast != "" {
+			fields["detailed_forecast"] = period.DetailedForecast
+		}
+		for name, value := range map[string]ApiValue{
+			"precipitation_probability": period.ProbabilityOfPrecipitation,
+			"humidity":                  period.RelativeHumidity,
+			"dewpoint":                  period.Dewpoint,
+		} {
+			if value.Value != nil {
+				n.addForecastValue(fields, name, value)
+			}
+		}
+		tags := g.tags()
+		if n.IncludeDaytimeTag {
+			tags["daytime"] = strconv.FormatBool(period.IsDaytime)
+		}
+		acc.AddFields(n.ForecastMeasurement, fields, tags, tm)
+	}
+	return nil
+}
+
+// addForecastValue adds the non-null forecast value to the fields converted
+// like an observation field. Unlike addValue, the unit is not recorded for
+// emit_unit_metadata and no raw value is added.
+func (n *NOAAWeatherAPI) addForecastValue(fields map[string]interface{}, name string, value ApiValue) {
+	fields[name] = n.convertValue(name, value)
+	if n.Units == "none" && value.UnitCode != "" {
+		fields[name+"_unit"] = value.UnitCode
+	}
+}
+
+func decodeForecast(r io.Reader) (*Forecast, error) {
+	forecast := &Forecast{}
+	if err := json.NewDecoder(r).Decode(forecast); err != nil {
+		return nil, fmt.Errorf("error while decoding JSON response: %s", err)
+	}
+	return forecast, nil
+}
+
+// forecastTemperature converts the forecast temperature, reported in °F by
+// default, to the configured unit system.
+func (n *NOAAWeatherAPI) forecastTemperature(period ForecastPeriod) float64 {
+	switch {
+	case n.Units == "metric" && period.TemperatureUnit == "F":
+		return (period.Temperature - 32) * 5.0 / 9.0
+	case n.Units == "imperial" && period.TemperatureUnit == "C":
+		return celsiusToFahrenheit(period.Temperature)
+	default:
+		return period.Temperature
+	}
+}
@@ -0,0 +1,269 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePointResponse = `
+{
+  "id": "https://api.weather.gov/points/27.18,-80.22",
+  "type": "Feature",
+  "properties": {
+    "cwa": "MFL",
+    "gridId": "MFL",
+    "gridX": 110,
+    "gridY": 50,
+    "forecast": "https://api.weather.gov/gridpoints/MFL/110,50/forecast"
+  }
+}
+`
+
+const sampleForecastResponse = `
+{
+  "type": "Feature",
+  "properties": {
+    "updated": "2021-11-07T19:25:47+00:00",
+    "units": "us",
+    "periods": [
+      {
+        "number": 1,
+        "name": "This Afternoon",
+        "startTime": "2021-11-07T14:00:00-05:00",
+        "endTime": "2021-11-07T18:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 77,
+        "temperatureUnit": "F",
+        "windSpeed": "14 mph",
+        "windDirection": "NNE",
+        "shortForecast": "Mostly Sunny"
+      },
+      {
+        "number": 2,
+        "name": "Tonight",
+        "startTime": "2021-11-07T18:00:00-05:00",
+        "endTime": "2021-11-08T06:00:00-05:00",
+        "isDaytime": false,
+        "temperature": 68,
+        "temperatureUnit": "F",
+        "windSpeed": "9 to 14 mph",
+        "windDirection": "NE",
+        "shortForecast": "Mostly Clear"
+      }
+    ]
+  }
+}
+`
+
+func newForecastServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/points/27.18,-80.22":
+			rsp = samplePointResponse
+		case "/gridpoints/MFL/110,50/forecast":
+			rsp = sampleForecastResponse
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+}
+
+func TestForecastGridpointTags(t *testing.T) {
+	ts := newForecastServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		plugin *NOAAWeatherAPI
+	}{
+		{
+			name:   "gridpoint",
+			plugin: &NOAAWeatherAPI{ForecastGridpoints: []string{"mfl/110,50"}},
+		},
+		{
+			name:   "point",
+			plugin: &NOAAWeatherAPI{ForecastPoints: []string{"27.18,-80.22"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.plugin
+			n.BaseURL = ts.URL
+			n.Units = "imperial"
+			require.NoError(t, n.Init())
+			require.Equal(t, []gridpoint{{Office: "MFL", X: 110, Y: 50}}, n.gridpoints)
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			tags := map[string]string{
+				"office": "MFL",
+				"grid_x": "110",
+				"grid_y": "50",
+			}
+			expected := []telegraf.Metric{
+				testutil.MustMetric(
+					"noaa_weather_forecast",
+					tags,
+					map[string]interface{}{
+						"period":         1,
+						"temperature":    float64(77),
+						"short_forecast": "Mostly Sunny",
+					},
+					time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC),
+				),
+				testutil.MustMetric(
+					"noaa_weather_forecast",
+					tags,
+					map[string]interface{}{
+						"period":         2,
+						"temperature":    float64(68),
+						"short_forecast": "Mostly Clear",
+					},
+					time.Date(2021, 11, 7, 23, 0, 0, 0, time.UTC),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+		})
+	}
+}
+
+func TestIncludeDaytimeTag(t *testing.T) {
+	ts := newForecastServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:            ts.URL,
+		ForecastGridpoints: []string{"MFL/110,50"},
+		IncludeDaytimeTag:  true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+
+	daytime := make(map[string]string)
+	for _, m := range acc.Metrics {
+		daytime[m.Fields["short_forecast"].(string)] = m.Tags["daytime"]
+	}
+	require.Equal(t, map[string]string{"Mostly Sunny": "true", "Mostly Clear": "false"}, daytime)
+}
+
+func TestForecastDetails(t *testing.T) {
+	const rsp = `
+{
+  "type": "Feature",
+  "properties": {
+    "periods": [
+      {
+        "number": 1,
+        "name": "Tonight",
+        "startTime": "2021-11-07T18:00:00-05:00",
+        "endTime": "2021-11-08T06:00:00-05:00",
+        "isDaytime": false,
+        "temperature": 68,
+        "temperatureUnit": "F",
+        "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": 40},
+        "dewpoint": {"unitCode": "wmoUnit:degC", "value": 18.3},
+        "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 87},
+        "shortForecast": "Chance Showers",
+        "detailedForecast": "A chance of showers after midnight. Mostly cloudy, with a low around 68."
+      },
+      {
+        "number": 2,
+        "name": "Monday",
+        "startTime": "2021-11-08T06:00:00-05:00",
+        "endTime": "2021-11-08T18:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 79,
+        "temperatureUnit": "F",
+        "probabilityOfPrecipitation": {"unitCode": "wmoUnit:percent", "value": null},
+        "shortForecast": "Sunny",
+        "detailedForecast": ""
+      }
+    ]
+  }
+}
+`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	// Raw values and unit metadata are limited to observations
+	n := &NOAAWeatherAPI{
+		BaseURL:            ts.URL,
+		Units:              "imperial",
+		ForecastGridpoints: []string{"MFL/110,50"},
+		IncludeRawValues:   true,
+		EmitUnitMetadata:   true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	tags := map[string]string{
+		"office": "MFL",
+		"grid_x": "110",
+		"grid_y": "50",
+	}
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"noaa_weather_forecast",
+			tags,
+			map[string]interface{}{
+				"period":                    1,
+				"temperature":               float64(68),
+				"short_forecast":            "Chance Showers",
+				"detailed_forecast":         "A chance of showers after midnight. Mostly cloudy, with a low around 68.",
+				"precipitation_probability": float64(40),
+				"humidity":                  float64(87),
+				"dewpoint":                  18.3,
+			},
+			time.Date(2021, 11, 7, 23, 0, 0, 0, time.UTC),
+		),
+		testutil.MustMetric(
+			"noaa_weather_forecast",
+			tags,
+			map[string]interface{}{
+				"period":         2,
+				"temperature":    float64(79),
+				"short_forecast": "Sunny",
+			},
+			time.Date(2021, 11, 8, 11, 0, 0, 0, time.UTC),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+}
+
+func TestParseGridpoint(t *testing.T) {
+	g, err := parseGridpoint("MFL/110,50")
+	require.NoError(t, err)
+	require.Equal(t, gridpoint{Office: "MFL", X: 110, Y: 50}, g)
+
+	for _, s := range []string{"MFL", "/110,50", "MFL/110", "MFL/x,50", "MFL/110,y"} {
+		_, err := parseGridpoint(s)
+		require.Error(t, err, s)
+	}
+}
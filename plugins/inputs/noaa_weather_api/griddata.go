@@ -0,0 +1,85 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// GridData is the response of the raw gridpoint endpoint. Its properties
+// hold the time series of the forecast parameters next to descriptive
+// properties, so they are only decoded for the selected parameters.
+type GridData struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+// GridSeries is the time series of a single forecast parameter.
+type GridSeries struct {
+	UnitOfMeasure string `json:"uom"`
+	Values        []struct {
+		ValidTime string   `json:"validTime"`
+		Value     *float64 `json:"value"`
+	} `json:"values"`
+}
+
+// gatherGridData reports every time step of the selected parameters of the
+// raw gridpoint data, timestamped with the start of the valid time.
+func (n *NOAAWeatherAPI) gatherGridData(ctx context.Context, acc telegraf.Accumulator, g gridpoint) error {
+	addr := n.resolveURL(fmt.Sprintf("/gridpoints/%s/%d,%d", g.Office, g.X, g.Y), nil)
+	resp, err := n.request(ctx, addr, geoJSONMediaTypes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := decodeGridData(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, parameter := range n.GridpointParameters {
+		raw, ok := data.Properties[parameter]
+		if !ok {
+			acc.AddError(fmt.Errorf("gridpoint %s returned no parameter %s", g, parameter))
+			continue
+		}
+		series := &GridSeries{}
+		if err := json.Unmarshal(raw, series); err != nil {
+			acc.AddError(fmt.Errorf("decoding parameter %s of gridpoint %s failed: %s", parameter, g, err))
+			continue
+		}
+
+		tags := g.tags()
+		tags["parameter"] = parameter
+		if series.UnitOfMeasure != "" {
+			tags["unit"] = series.UnitOfMeasure
+		}
+		for _, step := range series.Values {
+			if step.Value == nil {
+				continue
+			}
+			// The valid time is an ISO 8601 interval such as
+			// "2021-11-07T18:00:00+00:00/PT1H".
+			start := strings.SplitN(step.ValidTime, "/", 2)[0]
+			tm, err := parseTimestamp(start)
+			if err != nil {
+				acc.AddError(fmt.Errorf("parameter %s of gridpoint %s returned invalid valid time: %s", parameter, g, err))
+				continue
+			}
+			acc.AddFields(n.GridpointMeasurement, map[string]interface{}{"value": *step.Value}, tags, tm)
+		}
+	}
+	return nil
+}
+
+func decodeGridData(r io.Reader) (*GridData, error) {
+	data := &GridData{}
+	if err := json.NewDecoder(r).Decode(data); err != nil {
+		return nil, fmt.Errorf("error while decoding JSON response: %s", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,171 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGridDataResponse = `
+{
+  "id": "https://api.weather.gov/gridpoints/MFL/110,50",
+  "type": "Feature",
+  "properties": {
+    "updateTime": "2021-11-07T19:25:47+00:00",
+    "gridId": "MFL",
+    "gridX": "110",
+    "gridY": "50",
+    "elevation": {
+      "unitCode": "wmoUnit:m",
+      "value": 4.8768
+    },
+    "apparentTemperature": {
+      "uom": "wmoUnit:degC",
+      "values": [
+        {
+          "validTime": "2021-11-07T19:00:00+00:00/PT1H",
+          "value": 25.555555555555557
+        },
+        {
+          "validTime": "2021-11-07T20:00:00+00:00/PT2H",
+          "value": 24.444444444444443
+        },
+        {
+          "validTime": "2021-11-07T22:00:00+00:00/PT1H",
+          "value": null
+        }
+      ]
+    },
+    "skyCover": {
+      "uom": "wmoUnit:percent",
+      "values": [
+        {
+          "validTime": "2021-11-07T19:00:00+00:00/PT3H",
+          "value": 28
+        }
+      ]
+    },
+    "windGust": {
+      "uom": "wmoUnit:km_h-1",
+      "values": [
+        {
+          "validTime": "2021-11-07T19:00:00+00:00/PT3H",
+          "value": 33.336
+        }
+      ]
+    }
+  }
+}
+`
+
+func newGridDataServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gridpoints/MFL/110,50" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprint(w, sampleGridDataResponse)
+		require.NoError(t, err)
+	}))
+}
+
+func TestGridpointRaw(t *testing.T) {
+	ts := newGridDataServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		ForecastGridpoints:  []string{"MFL/110,50"},
+		GridpointRaw:        true,
+		GridpointParameters: []string{"apparentTemperature", "skyCover"},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	tags := func(parameter, unit string) map[string]string {
+		return map[string]string{
+			"office":    "MFL",
+			"grid_x":    "110",
+			"grid_y":    "50",
+			"parameter": parameter,
+			"unit":      unit,
+		}
+	}
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"noaa_weather_gridpoint",
+			tags("apparentTemperature", "wmoUnit:degC"),
+			map[string]interface{}{"value": 25.555555555555557},
+			time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC),
+		),
+		testutil.MustMetric(
+			"noaa_weather_gridpoint",
+			tags("apparentTemperature", "wmoUnit:degC"),
+			map[string]interface{}{"value": 24.444444444444443},
+			time.Date(2021, 11, 7, 20, 0, 0, 0, time.UTC),
+		),
+		testutil.MustMetric(
+			"noaa_weather_gridpoint",
+			tags("skyCover", "wmoUnit:percent"),
+			map[string]interface{}{"value": float64(28)},
+			time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+}
+
+func TestGridpointRawMissingParameter(t *testing.T) {
+	ts := newGridDataServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		ForecastGridpoints:  []string{"MFL/110,50"},
+		GridpointRaw:        true,
+		GridpointParameters: []string{"skyCover", "snowfallAmount"},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "snowfallAmount")
+	require.Len(t, acc.Metrics, 1)
+
+	n = &NOAAWeatherAPI{
+		ForecastGridpoints: []string{"MFL/110,50"},
+		GridpointRaw:       true,
+	}
+	require.Error(t, n.Init())
+}
+
+func TestGridpointMeasurement(t *testing.T) {
+	ts := newGridDataServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:              ts.URL,
+		ForecastGridpoints:   []string{"MFL/110,50"},
+		GridpointRaw:         true,
+		GridpointParameters:  []string{"skyCover"},
+		GridpointMeasurement: "weather_gridpoint",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, "weather_gridpoint", acc.Metrics[0].Measurement)
+}
@@ -0,0 +1,23 @@
+package noaa_weather_api
+
+import (
+	"sync/atomic"
+
+	"github.com/influxdata/telegraf"
+)
+
+// markReported records that an observation was reported during the current
+// gather.
+func (n *NOAAWeatherAPI) markReported() {
+	atomic.StoreInt32(&n.reported, 1)
+}
+
+// addHealth reports whether at least one station produced an observation
+// during the gather, so a plugin gathering nothing can be alerted on.
+func (n *NOAAWeatherAPI) addHealth(acc telegraf.Accumulator) {
+	healthy := 0
+	if atomic.LoadInt32(&n.reported) != 0 {
+		healthy = 1
+	}
+	acc.AddGauge("noaa_weather_health", map[string]interface{}{"plugin_healthy": healthy}, nil)
+}
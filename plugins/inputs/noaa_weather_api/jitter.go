@@ -0,0 +1,29 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// stationOffset returns the delay of the station's request relative to the
+// start of the gather. It is derived from a hash of the station identifier,
+// so a station is requested at the same point of every interval.
+func stationOffset(station string, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(station))
+	return time.Duration(h.Sum64() % uint64(jitter))
+}
+
+// waitForOffset delays the station's request until its offset from the
+// start of the gather passed.
+func (n *NOAAWeatherAPI) waitForOffset(ctx context.Context, station string, start time.Time) error {
+	offset := stationOffset(station, time.Duration(n.ScheduleJitter))
+	if delay := offset - n.now().Sub(start); delay > 0 {
+		return n.sleep(ctx, delay)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStationOffset(t *testing.T) {
+	jitter := 30 * time.Second
+	for _, station := range []string{"KSUA", "KFPR", "KVRB"} {
+		offset := stationOffset(station, jitter)
+		require.GreaterOrEqual(t, offset, time.Duration(0))
+		require.Less(t, offset, jitter)
+		require.Equal(t, offset, stationOffset(station, jitter))
+	}
+	require.NotEqual(t, stationOffset("KSUA", jitter), stationOffset("KFPR", jitter))
+	require.Equal(t, time.Duration(0), stationOffset("KSUA", 0))
+}
+
+func TestScheduleJitter(t *testing.T) {
+	jitter := 30 * time.Second
+	stations := []string{"KSUA", "KFPR", "KVRB", "KPBI"}
+	expected := make([]time.Duration, 0, len(stations))
+	for _, station := range stations {
+		offset := stationOffset(station, jitter)
+		require.Greater(t, offset, time.Duration(0), station)
+		expected = append(expected, offset)
+	}
+	require.False(t, sort.SliceIsSorted(expected, func(i, j int) bool { return expected[i] < expected[j] }))
+
+	var mu sync.Mutex
+	start := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	var delays []time.Duration
+	var requested int
+
+	n := &NOAAWeatherAPI{
+		BaseURL:        "https://api.weather.gov",
+		StationID:      stations,
+		MaxParallel:    2,
+		ScheduleJitter: config.Duration(jitter),
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested++
+			mu.Unlock()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     "200 OK",
+				Header:     http.Header{"Content-Type": []string{"application/ld+json"}},
+				Body:       io.NopCloser(strings.NewReader(sampleStatusResponse)),
+				Request:    req,
+			}, nil
+		}),
+	}
+	n.setNow(func() time.Time { return start })
+
+	// All stations wait for their offset at the same time instead of
+	// holding one of the max_parallel slots while waiting.
+	arrived := make(chan struct{}, len(stations))
+	release := make(chan struct{})
+	go func() {
+		for range stations {
+			<-arrived
+		}
+		close(release)
+	}()
+	n.setSleep(func(_ context.Context, d time.Duration) error {
+		mu.Lock()
+		delays = append(delays, d)
+		mu.Unlock()
+		arrived <- struct{}{}
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+			t.Error("stations did not wait concurrently")
+		}
+		return nil
+	})
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Equal(t, len(stations), requested)
+	require.ElementsMatch(t, expected, delays)
+
+	n = &NOAAWeatherAPI{
+		ScheduleJitter: config.Duration(-time.Second),
+	}
+	require.Error(t, n.Init())
+}
@@ -0,0 +1,79 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"sync"
+)
+
+// gatherMerged requests the observation of the station from both the
+// primary and the secondary observation path in parallel and merges the
+// two into a single observation. A failing secondary source is ignored.
+func (n *NOAAWeatherAPI) gatherMerged(ctx context.Context, station string) (*Status, error) {
+	var primary, secondary *Status
+	var primaryErr, secondaryErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		primary, primaryErr = n.gatherURL(ctx, n.formatURL(n.ObservationPath, station))
+	}()
+	go func() {
+		defer wg.Done()
+		secondary, secondaryErr = n.gatherURL(ctx, n.formatURL(n.SecondaryObservationPath, station))
+	}()
+	wg.Wait()
+
+	if primaryErr != nil {
+		return nil, primaryErr
+	}
+	if secondaryErr != nil {
+		n.Log.Debugf("Ignoring secondary observation of station %s: %s", station, secondaryErr)
+		return primary, nil
+	}
+	return mergeStatus(primary, secondary), nil
+}
+
+// mergeStatus merges two observations of the same station. Values of the
+// primary observation take precedence unless they are null or the secondary
+// value passed quality control while the primary one did not. Every field
+// of Status must be merged here; TestMergeStatusFields catches omissions.
+func mergeStatus(primary, secondary *Status) *Status {
+	merged := *primary
+	merged.Temperature = mergeValue(primary.Temperature, secondary.Temperature)
+	merged.Humidity = mergeValue(primary.Humidity, secondary.Humidity)
+	merged.BarometricPressure = mergeValue(primary.BarometricPressure, secondary.BarometricPressure)
+	merged.Visibility = mergeValue(primary.Visibility, secondary.Visibility)
+	merged.WindSpeed = mergeValue(primary.WindSpeed, secondary.WindSpeed)
+	merged.WindDirection = mergeValue(primary.WindDirection, secondary.WindDirection)
+	merged.Dewpoint = mergeValue(primary.Dewpoint, secondary.Dewpoint)
+	merged.HeatIndex = mergeValue(primary.HeatIndex, secondary.HeatIndex)
+	merged.WindChill = mergeValue(primary.WindChill, secondary.WindChill)
+	merged.SeaLevelPressure = mergeValue(primary.SeaLevelPressure, secondary.SeaLevelPressure)
+	if len(merged.CloudLayers) == 0 {
+		merged.CloudLayers = secondary.CloudLayers
+	}
+	if merged.Timestamp == "" {
+		merged.Timestamp = secondary.Timestamp
+	}
+	if merged.RawMessage == "" {
+		merged.RawMessage = secondary.RawMessage
+	}
+	if merged.Station == "" {
+		merged.Station = secondary.Station
+	}
+	return &merged
+}
+
+func mergeValue(primary, secondary ApiValue) ApiValue {
+	switch {
+	case secondary.Value == nil:
+		return primary
+	case primary.Value == nil:
+		return secondary
+	case primary.QualityControl != "V" && secondary.QualityControl == "V":
+		return secondary
+	default:
+		return primary
+	}
+}
@@ -0,0 +1,108 @@
+package noaa_weather_api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleMetarPartialResponse = `
+{
+  "station": "https://api.weather.gov/stations/KSUA",
+  "timestamp": "2021-11-07T18:50:00+00:00",
+  "rawMessage": "KSUA 071850Z 34012G21KT 10SM FEW075 21/11 A2998",
+  "temperature": {"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "V"},
+  "dewpoint": {"unitCode": "wmoUnit:degC", "value": 11, "qualityControl": "V"},
+  "windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": null, "qualityControl": "Z"},
+  "windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": null, "qualityControl": "Z"},
+  "barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101520, "qualityControl": "S"},
+  "visibility": {"unitCode": "wmoUnit:m", "value": 16090, "qualityControl": "C"},
+  "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": null, "qualityControl": "Z"}
+}
+`
+
+const sampleMesonetPartialResponse = `
+{
+  "station": "https://api.weather.gov/stations/KSUA",
+  "timestamp": "2021-11-07T18:45:00+00:00",
+  "temperature": {"unitCode": "wmoUnit:degC", "value": 20, "qualityControl": "V"},
+  "dewpoint": {"unitCode": "wmoUnit:degC", "value": null, "qualityControl": "Z"},
+  "windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": 340, "qualityControl": "V"},
+  "windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": 22.32, "qualityControl": "V"},
+  "barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101500, "qualityControl": "V"},
+  "visibility": {"unitCode": "wmoUnit:m", "value": null, "qualityControl": "Z"},
+  "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 52.802638324228, "qualityControl": "V"}
+}
+`
+
+func TestSecondaryObservationPath(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleMetarPartialResponse,
+		"/stations/KSUA/mesonet/latest":      sampleMesonetPartialResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                  ts.URL,
+		StationID:                []string{"KSUA"},
+		Units:                    "metric",
+		SecondaryObservationPath: "/stations/%s/mesonet/latest",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"noaa_weather",
+			map[string]string{
+				"station": "KSUA",
+			},
+			map[string]interface{}{
+				"temperature":  float64(21),
+				"humidity":     float64(52.802638324228),
+				"pressure":     float64(101500),
+				"visibility":   float64(16090),
+				"dewpoint":     float64(11),
+				"wind_speed":   float64(22.32),
+				"wind_degrees": float64(340),
+			},
+			time.Unix(1636311000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	n = &NOAAWeatherAPI{
+		SecondaryObservationPath: "/stations/mesonet/latest",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestMergeStatusFields(t *testing.T) {
+	// Every field is only set in the secondary observation, so each one
+	// missed by mergeStatus stays empty.
+	secondary := &Status{}
+	v := reflect.ValueOf(secondary).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Interface().(type) {
+		case ApiValue:
+			value := float64(i)
+			field.Set(reflect.ValueOf(ApiValue{UnitCode: "wmoUnit:m", Value: &value, QualityControl: "V"}))
+		case string:
+			field.SetString(v.Type().Field(i).Name)
+		case []CloudLayer:
+			field.Set(reflect.ValueOf([]CloudLayer{{Amount: "OVC"}}))
+		default:
+			t.Fatalf("field %s of unsupported type %s", v.Type().Field(i).Name, field.Type())
+		}
+	}
+
+	require.Equal(t, secondary, mergeStatus(&Status{}, secondary))
+}
@@ -0,0 +1,151 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StationMetadata holds the properties returned by the station endpoint.
+type StationMetadata struct {
+	StationIdentifier string   `json:"stationIdentifier"`
+	Name              string   `json:"name"`
+	TimeZone          string   `json:"timeZone"`
+	County            string   `json:"county"`
+	Elevation         ApiValue `json:"elevation"`
+	Status            string   `json:"status"`
+}
+
+// Tags returns the tags describing the station. The state is derived from
+// the county zone identifier, whose first two letters are the state code.
+// Stations reporting a status are tagged as active if it is "active".
+func (m *StationMetadata) Tags() map[string]string {
+	tags := make(map[string]string)
+	if m.Name != "" {
+		tags["name"] = m.Name
+	}
+	if m.TimeZone != "" {
+		tags["time_zone"] = m.TimeZone
+	}
+	if county := lastPathSegment(m.County); county != "" {
+		tags["county"] = county
+		if len(county) > 2 {
+			tags["state"] = county[:2]
+		}
+	}
+	if m.Status != "" {
+		tags["active"] = strconv.FormatBool(strings.EqualFold(m.Status, "active"))
+	}
+	return tags
+}
+
+// stationMetadata returns the metadata of the station, requesting it from
+// the API the first time it is needed.
+func (n *NOAAWeatherAPI) stationMetadata(ctx context.Context, station string) (*StationMetadata, error) {
+	n.metadataLock.Lock()
+	meta, ok := n.metadata[station]
+	n.metadataLock.Unlock()
+	if ok {
+		return meta, nil
+	}
+
+	meta, err := n.gatherStationMeta(ctx, n.formatStationURL(station))
+	if err != nil {
+		return nil, err
+	}
+
+	n.metadataLock.Lock()
+	n.metadata[station] = meta
+	n.metadataLock.Unlock()
+	return meta, nil
+}
+
+// prefetchMetadata requests the metadata of all stations concurrently,
+// bounded by max_parallel, to avoid a slow first gather. Failures are
+// logged and retried on gather, unless strict_prefetch is set.
+func (n *NOAAWeatherAPI) prefetchMetadata() error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+
+	sem := make(chan struct{}, n.MaxParallel)
+	for _, station := range n.StationID {
+		station := station
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if _, err := n.stationMetadata(context.Background(), station); err != nil {
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s (%s)", station, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+	if n.StrictPrefetch {
+		return fmt.Errorf("prefetching metadata failed: %s", strings.Join(failed, ", "))
+	}
+	n.Log.Warnf("Prefetching metadata failed: %s", strings.Join(failed, ", "))
+	return nil
+}
+
+// cachedMetadata returns the metadata of the station if it was fetched
+// before, or nil otherwise.
+func (n *NOAAWeatherAPI) cachedMetadata(station string) *StationMetadata {
+	n.metadataLock.Lock()
+	defer n.metadataLock.Unlock()
+	return n.metadata[station]
+}
+
+func (n *NOAAWeatherAPI) gatherStationMeta(ctx context.Context, addr string) (*StationMetadata, error) {
+	resp, err := n.request(ctx, addr, ldJSONMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeStationMeta(resp.Body)
+}
+
+func decodeStationMeta(r io.Reader) (*StationMetadata, error) {
+	dec := json.NewDecoder(r)
+	meta := &StationMetadata{}
+	if err := dec.Decode(meta); err != nil {
+		return nil, fmt.Errorf("error while decoding JSON response: %s", err)
+	}
+	return meta, nil
+}
+
+func (n *NOAAWeatherAPI) formatStationURL(station string) string {
+	return n.resolveURL(fmt.Sprintf("/stations/%s", url.PathEscape(station)), nil)
+}
+
+// lastPathSegment returns the last path element of an API resource URL,
+// such as the zone identifier of a county zone URL.
+func lastPathSegment(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	segment := path.Base(u.Path)
+	if segment == "/" || segment == "." {
+		return ""
+	}
+	return segment
+}
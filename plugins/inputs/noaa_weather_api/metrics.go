@@ -0,0 +1,105 @@
+package noaa_weather_api
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// GatherErrors holds the errors that occurred during a gather.
+type GatherErrors []error
+
+func (e GatherErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// stationFailure marks the error of a station that could not be gathered,
+// as opposed to diagnostics such as unknown units; only the former fail the
+// gather with fail_fast.
+type stationFailure struct {
+	err error
+}
+
+func (e *stationFailure) Error() string {
+	return e.err.Error()
+}
+
+func (e *stationFailure) Unwrap() error {
+	return e.err
+}
+
+// metricAccumulator collects the metrics and errors of a gather in memory.
+type metricAccumulator struct {
+	sync.Mutex
+
+	now     func() time.Time
+	metrics []telegraf.Metric
+	errors  GatherErrors
+}
+
+func (a *metricAccumulator) addFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	tp telegraf.ValueType,
+	t ...time.Time,
+) {
+	if len(fields) == 0 {
+		return
+	}
+
+	tm := a.now()
+	if len(t) > 0 {
+		tm = t[0]
+	}
+	a.AddMetric(metric.New(measurement, tags, fields, tm, tp))
+}
+
+func (a *metricAccumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addFields(measurement, fields, tags, telegraf.Untyped, t...)
+}
+
+func (a *metricAccumulator) AddGauge(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addFields(measurement, fields, tags, telegraf.Gauge, t...)
+}
+
+func (a *metricAccumulator) AddCounter(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addFields(measurement, fields, tags, telegraf.Counter, t...)
+}
+
+func (a *metricAccumulator) AddSummary(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addFields(measurement, fields, tags, telegraf.Summary, t...)
+}
+
+func (a *metricAccumulator) AddHistogram(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addFields(measurement, fields, tags, telegraf.Histogram, t...)
+}
+
+func (a *metricAccumulator) AddMetric(m telegraf.Metric) {
+	a.Lock()
+	defer a.Unlock()
+	a.metrics = append(a.metrics, m)
+}
+
+func (a *metricAccumulator) SetPrecision(time.Duration) {}
+
+func (a *metricAccumulator) AddError(err error) {
+	if err == nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.errors = append(a.errors, err)
+}
+
+func (a *metricAccumulator) WithTracking(int) telegraf.TrackingAccumulator {
+	panic("tracking is not supported")
+}
@@ -1,17 +1,33 @@
 package noaa_weather_api
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -23,41 +39,301 @@ const (
 	defaultBaseURL                 = "https://api.weather.gov/"
 	defaultResponseTimeout         = time.Second * 5
 	defaultUnits                   = "imperial"
+	defaultAcceptLanguage          = "en-US"
+	defaultSourceTag               = "nws"
+	defaultMaxBodySize             = 5 * 1024 * 1024
+	defaultObservationPath         = "/stations/%s/observations/latest"
+	defaultMaxParallel             = 10
+	defaultMaxZoneStations         = 50
+	defaultStationQueryLimit       = 50
+	defaultCircuitCooldown         = time.Minute * 10
+	defaultMaxIdleConns            = 100
+	defaultIdleConnTimeout         = time.Second * 90
+	defaultRetryBaseDelay          = time.Second
+	defaultRetryMaxDelay           = time.Second * 30
+
+	defaultObservationMeasurement = "noaa_weather"
+	defaultForecastMeasurement    = "noaa_weather_forecast"
+	defaultAlertMeasurement       = "noaa_weather_alert_counts"
+	defaultGridpointMeasurement   = "noaa_weather_gridpoint"
+
+	// Number of bytes of an unexpected response body included in errors.
+	maxBodySnippet = 256
+
+	// Number of bytes of an error response decoded as problem details.
+	maxProblemSize = 64 * 1024
+
+	// Pressure changes below this many hPa are reported as steady.
+	pressureSteadyThreshold = 0.1
 )
 
+// Layouts tried in order when parsing observation timestamps. Timestamps
+// without a zone are assumed to be UTC.
+var timestampLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+}
+
+var stationIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,16}$`)
+
+// pathPlaceholderPattern matches the named placeholders of the observation
+// paths; only unitsPlaceholder is supported.
+var pathPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+const unitsPlaceholder = "{units}"
+
 type NOAAWeatherAPI struct {
 	StationID       []string        `toml:"station_id"`
+	ZoneID          string          `toml:"zone_id"`
+	MaxZoneStations int             `toml:"max_zone_stations"`
 	BaseURL         string          `toml:"base_url"`
+	FallbackURLs    []string        `toml:"fallback_urls"`
 	ResponseTimeout config.Duration `toml:"response_timeout"`
+	MaxBodySize     config.Size     `toml:"max_body_size"`
+	DialTimeout     config.Duration `toml:"dial_timeout"`
+	IPVersion       string          `toml:"ip_version"`
 	Units           string          `toml:"units"`
+	VisibilityUnit  string          `toml:"visibility_unit"`
+	ServerSideUnits string          `toml:"server_side_units"`
+	IncludeUnitsTag bool            `toml:"include_units_tag"`
+	IncludeHourTag  bool            `toml:"include_hour_tag"`
 	UserAgent       string          `toml:"user_agent"`
-	client          *http.Client
-	baseParsedURL   *url.URL
+	AcceptLanguage  string          `toml:"accept_language"`
+	ObservationPath string          `toml:"observation_path"`
+	File            string          `toml:"file"`
+
+	StationQuery      string `toml:"station_query"`
+	StationQueryLimit int    `toml:"station_query_limit"`
+
+	SecondaryObservationPath string `toml:"secondary_observation_path"`
+	AcceptHeader             string `toml:"accept_header"`
+	ExpectedContentType      string `toml:"expected_content_type"`
+	ProbeMethod              string `toml:"probe_method"`
+
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Absolute path to file with Bearer token
+	BearerToken string `toml:"bearer_token"`
+
+	// Directory of PEM files added to the certificate authorities
+	TLSCADir string `toml:"tls_ca_dir"`
+	tlsint.ClientConfig
+
+	IncludeRawMessage bool            `toml:"include_raw_message"`
+	HistoryStart      string          `toml:"history_start"`
+	HistoryDuration   config.Duration `toml:"history_duration"`
+	CollectStats      bool            `toml:"collect_stats"`
+	EmitUnavailable   bool            `toml:"emit_unavailable"`
+	UseNowOnParseErr  bool            `toml:"use_now_on_parse_error"`
+	MaxObservationAge config.Duration `toml:"max_observation_age"`
+
+	IncludeStationMetadata bool              `toml:"include_station_metadata"`
+	PrefetchMetadata       bool              `toml:"prefetch_metadata"`
+	StrictPrefetch         bool              `toml:"strict_prefetch"`
+	FailFast               bool              `toml:"fail_fast"`
+	StationLabels          map[string]string `toml:"station_labels"`
+	LabelRequired          bool              `toml:"label_required"`
+	AllowDuplicateStations bool              `toml:"allow_duplicate_stations"`
+	StationTagKey          string            `toml:"station_tag_key"`
+	SourceTag              string            `toml:"source_tag"`
+	StationFile            string            `toml:"station_file"`
+	StationFileRefresh     config.Duration   `toml:"station_file_refresh"`
+	ObservationCacheTTL    config.Duration   `toml:"observation_cache_ttl"`
+
+	ClampHumidity bool   `toml:"clamp_humidity"`
+	RequireQC     bool   `toml:"require_qc"`
+	QCJSONKey     string `toml:"qc_json_key"`
+
+	IncludeQCRequired bool `toml:"include_qc_required"`
+	IncludeRawValues  bool `toml:"include_raw_values"`
+
+	UnitOverrides []UnitOverride `toml:"unit_overrides"`
+
+	CompareStations [][]string `toml:"compare_stations"`
+
+	SanityChecks bool                 `toml:"sanity_checks"`
+	SanityLimits map[string][]float64 `toml:"sanity_limits"`
+
+	IncludeWindCardinal bool   `toml:"include_wind_cardinal"`
+	WindComponents      bool   `toml:"wind_components"`
+	WindComponentsOnly  bool   `toml:"wind_components_only"`
+	FieldPrefix         string `toml:"field_prefix"`
+	RoundDecimals       *int   `toml:"round_decimals"`
+	DeltaOnly           bool   `toml:"delta_only"`
+
+	SkipDuplicateObservations bool `toml:"skip_duplicate_observations"`
+
+	IncludePressureTendency bool `toml:"include_pressure_tendency"`
+	SkipWarmupFields        bool `toml:"skip_warmup_fields"`
+
+	CollectCompleteness bool `toml:"collect_completeness"`
+	ComputeDerived      bool `toml:"compute_derived"`
+	IncludeAge          bool `toml:"include_age"`
+	StrictDecoding      bool `toml:"strict_decoding"`
+	EmitUnitMetadata    bool `toml:"emit_unit_metadata"`
+	EmitHealth          bool `toml:"emit_health"`
+	TraceRequests       bool `toml:"trace_requests"`
+
+	ComputeAbsoluteHumidity bool `toml:"compute_absolute_humidity"`
+	ComputeSeaLevelPressure bool `toml:"compute_sea_level_pressure"`
+
+	MetricLayout    string `toml:"metric_layout"`
+	TimestampSource string `toml:"timestamp_source"`
+
+	ObservationMeasurement string `toml:"observation_measurement"`
+	ForecastMeasurement    string `toml:"forecast_measurement"`
+	AlertMeasurement       string `toml:"alert_measurement"`
+	GridpointMeasurement   string `toml:"gridpoint_measurement"`
+
+	ForecastGridpoints []string `toml:"forecast_gridpoints"`
+	ForecastPoints     []string `toml:"forecast_points"`
+	IncludeDaytimeTag  bool     `toml:"include_daytime_tag"`
+
+	GridpointRaw        bool     `toml:"gridpoint_raw"`
+	GridpointParameters []string `toml:"gridpoint_parameters"`
+
+	CollectAlertCounts bool `toml:"collect_alert_counts"`
+
+	FieldsInclude []string `toml:"fields_include"`
+	FieldsExclude []string `toml:"fields_exclude"`
+
+	MaxParallel    int             `toml:"max_parallel"`
+	BatchRequests  bool            `toml:"batch_requests"`
+	ScheduleJitter config.Duration `toml:"schedule_jitter"`
+
+	MaxIdleConns        int             `toml:"max_idle_conns"`
+	MaxIdleConnsPerHost int             `toml:"max_idle_conns_per_host"`
+	IdleConnTimeout     config.Duration `toml:"idle_conn_timeout"`
+
+	MaxRetries     int             `toml:"max_retries"`
+	RetryBaseDelay config.Duration `toml:"retry_base_delay"`
+	RetryMaxDelay  config.Duration `toml:"retry_max_delay"`
+	RetryOn        []string        `toml:"retry_on"`
+
+	RateLimit    float64 `toml:"rate_limit"`
+	RateLimitKey string  `toml:"rate_limit_key"`
+
+	FailureThreshold int             `toml:"failure_threshold"`
+	CircuitCooldown  config.Duration `toml:"circuit_cooldown"`
+
+	MaxRequestsPerInterval int  `toml:"max_requests_per_interval"`
+	BudgetRoundRobin       bool `toml:"budget_round_robin"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	// Transport replaces the transport of the HTTP client if set, e.g. to
+	// simulate responses in tests. dial_timeout, ip_version and the
+	// connection pool options do not apply then; the TLS options are only
+	// supported for an *http.Transport.
+	Transport http.RoundTripper `toml:"-"`
+
+	client        *http.Client
+	baseParsedURL *url.URL
+	historyStart  time.Time
+	gridpoints    []gridpoint
+
+	// base_url followed by fallback_urls, and the index, accessed
+	// atomically, of the one the last observation was requested from
+	baseURLs     []*url.URL
+	lastGoodBase int32
+
+	// Stations of station_id and the zone the stations of station_file are
+	// merged into, and the time station_file was last read
+	staticStations  []string
+	stationFileRead time.Time
+
+	// Requests made during the current gather, accessed atomically, and
+	// the position in the stations the gather starts at with
+	// budget_round_robin
+	requestCount int32
+	budgetOffset int
+
+	// Set, accessed atomically, once an observation was reported during
+	// the current gather
+	reported int32
+
+	// Failures retried according to retry_on
+	retryOn map[string]bool
+
+	// Limiter of rate_limit, possibly shared with other instances
+	limiter *rateLimiter
+
+	// Unit conversions of the configured unit system
+	conversions map[string]converter
+	overridden  map[string]bool
+
+	// Clock used for all time reads and the function used for all waits,
+	// i.e. schedule_jitter, rate_limit and retry backoffs, replaceable in
+	// tests. Waits end early once the context is cancelled.
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+
+	statsLock sync.Mutex
+	stats     map[string]*stationStats
+
+	metadataLock sync.Mutex
+	metadata     map[string]*StationMetadata
+
+	stateLock sync.Mutex
+	state     map[string]*stationState
+
+	// Unit codes without a conversion seen during the current gather.
+	unknownUnitsLock sync.Mutex
+	unknownUnits     map[string]bool
+
+	// Unit codes of the fields seen during the current gather.
+	sourceUnitsLock sync.Mutex
+	sourceUnits     map[string]string
+
+	// Observation values of the stations of compare_stations reported
+	// during the current gather.
+	comparedLock sync.Mutex
+	compared     map[string]map[string]float64
 }
 
-var sampleConfig = `
-  ## NOAA Weather API
+// stationState holds the readings of a station kept between gathers.
+type stationState struct {
+	// Pressure and timestamp of the previous observation, with
+	// include_pressure_tendency
+	lastPressure     *float64
+	lastPressureTime time.Time
+
+	// Whether the first observation of the station was gathered
+	warmedUp bool
+
+	// Circuit breaker of the station
+	failures         int
+	breakerOpen      bool
+	breakerOpenUntil time.Time
 
-  ## Stations to collect weather data from.
-  station_id = ["KSUA"]
+	// Whether an observation from the future was logged
+	futureLogged bool
 
-  ## base URL
-  # base_url = "https://api.weather.gov"
+	// Latest observation and when it was requested, with
+	// observation_cache_ttl
+	cached   *Status
+	cachedAt time.Time
 
-  ## Timeout for HTTP response.
-  # response_timeout = "5s"
+	// Fields reported with the previous observation, with delta_only
+	lastFields map[string]interface{}
 
-  ## Preferred unit system for temperature and wind speed. Can be one of
-  ## "metric" or "imperial".
-  # units = "imperial"
+	// Timestamp of the previously reported observation, with
+	// skip_duplicate_observations
+	lastReported time.Time
+}
+
+// stationStats holds the running request counters of a station.
+type stationStats struct {
+	requests        int64
+	errors          int64
+	humidityClamped int64
+	sanityDropped   int64
+}
 
-  ## Query interval;
-  ## minutes.
-  interval = "10m"
-  
-  ## UserAgent
-  user_agent = "Your Server name <you@email.com>"
-`
+//go:embed sample.conf
+var sampleConfig string
 
 func (n *NOAAWeatherAPI) SampleConfig() string {
 	return sampleConfig
@@ -68,181 +344,1833 @@ func (n *NOAAWeatherAPI) Description() string {
 }
 
 func (n *NOAAWeatherAPI) Gather(acc telegraf.Accumulator) error {
+	metrics, err := n.GatherMetrics(context.Background())
+	for _, m := range metrics {
+		acc.AddMetric(m)
+	}
+	// With fail_fast the stations that failed fail the gather instead of
+	// being reported individually, the metrics gathered successfully and
+	// other errors such as unknown units are still added.
+	var failed GatherErrors
+	if errs, ok := err.(GatherErrors); ok {
+		for _, err := range errs {
+			var serr *stationFailure
+			if n.FailFast && errors.As(err, &serr) {
+				failed = append(failed, err)
+				continue
+			}
+			acc.AddError(err)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// GatherMetrics gathers all configured stations, forecasts and alerts and
+// returns the resulting metrics instead of adding them to an accumulator.
+// Errors of the individual requests are returned as GatherErrors along with
+// the metrics gathered successfully. Once the context is cancelled, pending
+// requests are aborted and the stations affected are not reported.
+func (n *NOAAWeatherAPI) GatherMetrics(ctx context.Context) ([]telegraf.Metric, error) {
+	collector := &metricAccumulator{now: n.now}
+	n.gather(ctx, &dedupAccumulator{Accumulator: collector, seen: make(map[string]bool)})
+	if n.SourceTag != "" {
+		for _, m := range collector.metrics {
+			m.AddTag("source", n.SourceTag)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		collector.AddError(err)
+	}
+	if len(collector.errors) > 0 {
+		return collector.metrics, collector.errors
+	}
+	return collector.metrics, nil
+}
+
+func (n *NOAAWeatherAPI) gather(ctx context.Context, acc telegraf.Accumulator) {
+	var wg sync.WaitGroup
+
+	n.unknownUnitsLock.Lock()
+	n.unknownUnits = make(map[string]bool)
+	n.unknownUnitsLock.Unlock()
+
+	n.sourceUnitsLock.Lock()
+	n.sourceUnits = make(map[string]string)
+	n.sourceUnitsLock.Unlock()
+
+	n.comparedLock.Lock()
+	n.compared = make(map[string]map[string]float64)
+	n.comparedLock.Unlock()
+
+	if n.StationFile != "" && n.StationFileRefresh > 0 && n.now().Sub(n.stationFileRead) >= time.Duration(n.StationFileRefresh) {
+		if err := n.loadStationFile(); err != nil {
+			acc.AddError(err)
+		}
+	}
+
+	atomic.StoreInt32(&n.requestCount, 0)
+	atomic.StoreInt32(&n.reported, 0)
+
+	if n.File != "" {
+		if err := n.gatherFile(acc); err != nil {
+			acc.AddError(err)
+		}
+		n.finishGather(ctx, acc)
+		return
+	}
+
+	stations := n.StationID
+	if n.BudgetRoundRobin {
+		stations = rotateStations(stations, n.budgetOffset)
+	}
+	var skips budgetSkips
+
+	// The cancellation is reported once by GatherMetrics instead of for
+	// every aborted request.
+	addError := func(err error) {
+		if ctx.Err() == nil {
+			acc.AddError(err)
+		}
+	}
+
+	// Limit the number of requests running at once
+	sem := make(chan struct{}, n.MaxParallel)
+	run := func(f func()) {
+		if ctx.Err() != nil {
+			return
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f()
+		}()
+	}
+
+	if n.BatchRequests {
+		for i, batch := range batches(stations, nwaRequestSeveralStationID) {
+			position, batch := i*nwaRequestSeveralStationID, batch
+			run(func() {
+				err := n.gatherBatch(ctx, acc, batch)
+				switch {
+				case errors.Is(err, errBudgetExhausted):
+					skips.add(position, len(batch))
+				case err != nil:
+					addError(&stationFailure{err: err})
+				}
+			})
+		}
+	} else {
+		start := n.now()
+		for i, station := range stations {
+			position, station := i, station
+			gather := func() {
+				err := n.gatherStation(ctx, acc, station)
+				switch {
+				case errors.Is(err, errBudgetExhausted):
+					skips.add(position, 1)
+				case err != nil:
+					addError(&stationFailure{err: fmt.Errorf("station %s: %w", station, err)})
+				}
+			}
+			if n.ScheduleJitter <= 0 {
+				run(gather)
+				continue
+			}
+
+			// Wait for the offset before taking a slot of max_parallel, so
+			// waiting stations do not block the ones due earlier.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if n.waitForOffset(ctx, station, start) == nil {
+					run(gather)
+				}
+			}()
+		}
+	}
+
+	for _, g := range n.gridpoints {
+		g := g
+		run(func() {
+			gather := n.gatherForecast
+			if n.GridpointRaw {
+				gather = n.gatherGridData
+			}
+			if err := gather(ctx, acc, g); err != nil {
+				addError(err)
+			}
+		})
+	}
+
+	if n.CollectAlertCounts {
+		run(func() {
+			if err := n.gatherAlertCounts(ctx, acc); err != nil {
+				addError(fmt.Errorf("getting alert counts failed: %s", err))
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if skips.count > 0 {
+		n.Log.Warnf("Request budget of %d exhausted, skipped %d stations", n.MaxRequestsPerInterval, skips.count)
+		if n.BudgetRoundRobin {
+			// Start with the first skipped station in the next interval
+			n.budgetOffset = (n.budgetOffset + skips.first) % len(n.StationID)
+		}
+	}
+
+	n.finishGather(ctx, acc)
+}
+
+// finishGather adds the metrics derived from the reported observations.
+func (n *NOAAWeatherAPI) finishGather(ctx context.Context, acc telegraf.Accumulator) {
+	if n.EmitUnitMetadata {
+		n.addUnitMetadata(acc)
+	}
+	if len(n.CompareStations) > 0 {
+		n.addDeltas(acc)
+	}
+	if n.EmitHealth && ctx.Err() == nil {
+		n.addHealth(acc)
+	}
+}
+
+// Validate checks that the latest observation of every configured station
+// can be requested. Unlike Init, which only checks the configuration, it
+// contacts the API and must be called after Init.
+func (n *NOAAWeatherAPI) Validate() error {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
 
 	for _, station := range n.StationID {
-		addr := n.formatURL("/stations/%s/observations/latest", station)
+		station := station
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			status, err := n.gatherURL(addr)
+			resp, err := n.probe(n.formatURL(n.ObservationPath, station))
 			if err != nil {
-				acc.AddError(err)
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s (%s)", station, err))
+				mu.Unlock()
 				return
 			}
-
-			n.GatherWeather(acc, status)
+			resp.Body.Close()
 		}()
 	}
-
 	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("unreachable stations: %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
 
-func (n *NOAAWeatherAPI) createHTTPClient() *http.Client {
-	if n.ResponseTimeout < config.Duration(time.Second) {
-		n.ResponseTimeout = config.Duration(defaultResponseTimeout)
+// probe requests the observation with probe_method, falling back to GET if
+// the server does not allow HEAD requests.
+func (n *NOAAWeatherAPI) probe(addr string) (*http.Response, error) {
+	resp, err := n.requestObservation(context.Background(), n.ProbeMethod, addr)
+	var serr *statusError
+	if n.ProbeMethod == http.MethodHead && errors.As(err, &serr) && serr.code == http.StatusMethodNotAllowed {
+		n.Log.Debugf("HEAD not allowed by %s, falling back to GET", addr)
+		return n.requestObservation(context.Background(), http.MethodGet, addr)
 	}
+	return resp, err
+}
 
-	client := &http.Client{
-		Transport: &http.Transport{},
-		Timeout:   time.Duration(n.ResponseTimeout),
+// addAvailability reports whether the station answered the request or
+// responded with an HTTP error such as 404 Not Found.
+func (n *NOAAWeatherAPI) addAvailability(acc telegraf.Accumulator, station string, err error) {
+	var serr *statusError
+	available := 1
+	if errors.As(err, &serr) {
+		available = 0
+	} else if err != nil {
+		// Other errors do not tell anything about the station.
+		return
 	}
+	acc.AddFields("noaa_weather_availability", map[string]interface{}{"available": available}, n.stationTags(station))
+}
+
+// dedupAccumulator drops errors identical to one already reported during
+// the same gather, e.g. when many stations fail for the same reason.
+type dedupAccumulator struct {
+	telegraf.Accumulator
 
-	return client
+	sync.Mutex
+	seen map[string]bool
 }
 
-func (n *NOAAWeatherAPI) gatherURL(addr string) (*Status, error) {
-	req, err := http.NewRequest("GET", addr, nil)
-	req.Header.Add("Accept", "application/ld+json")
-	req.Header.Add("User-Agent", n.UserAgent)
-	resp, err := n.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making HTTP request to %s: %s", addr, err)
+func (a *dedupAccumulator) AddError(err error) {
+	if err == nil {
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s returned HTTP status %s", addr, resp.Status)
+	a.Lock()
+	seen := a.seen[err.Error()]
+	a.seen[err.Error()] = true
+	a.Unlock()
+
+	if !seen {
+		a.Accumulator.AddError(err)
 	}
+}
 
-	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+func (n *NOAAWeatherAPI) gatherStation(ctx context.Context, acc telegraf.Accumulator, station string) error {
+	if !n.allowRequest(station) {
+		return nil
+	}
+
+	start := n.now()
+	statuses, err := n.fetchObservations(ctx, station)
+	duration := n.now().Sub(start)
+	if errors.Is(err, errBudgetExhausted) {
+		return err
+	}
+	return n.reportStation(ctx, acc, station, statuses, duration, err)
+}
+
+// reportStation reports the observations of the station requested in the
+// given duration, or the error requesting them failed with, updating the
+// circuit breaker and the availability and request statistics.
+func (n *NOAAWeatherAPI) reportStation(
+	ctx context.Context,
+	acc telegraf.Accumulator,
+	station string,
+	statuses []*Status,
+	duration time.Duration,
+	err error,
+) error {
+	if err == nil && n.IncludeStationMetadata {
+		if _, err := n.stationMetadata(ctx, station); err != nil && ctx.Err() == nil {
+			acc.AddError(fmt.Errorf("getting metadata of station %s failed: %s", station, err))
+		}
+	}
+	// Stations aborted by the cancellation are not reported at all.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n.recordResult(station, err)
+	if n.EmitUnavailable {
+		n.addAvailability(acc, station, err)
+	}
+	if n.CollectStats {
+		defer func() {
+			n.addStats(acc, station, duration, err)
+		}()
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	for _, status := range statuses {
+		n.gatherWeather(acc, station, status)
+	}
+	return nil
+}
+
+// fetchObservations requests either the latest observation or, when a
+// history window is configured, all observations in that window.
+func (n *NOAAWeatherAPI) fetchObservations(ctx context.Context, station string) ([]*Status, error) {
+	if n.HistoryDuration > 0 {
+		start, end := n.historyWindow()
+		history, err := n.gatherHistoryURL(ctx, n.formatHistoryURL(station, start, end))
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := make([]*Status, 0, len(history.Features))
+		for i := range history.Features {
+			statuses = append(statuses, &history.Features[i].Properties)
+		}
+		sortByTimestamp(statuses)
+		return statuses, nil
 	}
 
-	if mediaType != "application/ld+json" {
-		return nil, fmt.Errorf("%s returned unexpected content type %s", addr, mediaType)
+	if n.ObservationCacheTTL > 0 {
+		if status := n.cachedObservation(station); status != nil {
+			return []*Status{status}, nil
+		}
 	}
 
-	return gatherWeatherURL(resp.Body)
+	var status *Status
+	var err error
+	if n.SecondaryObservationPath != "" {
+		status, err = n.gatherMerged(ctx, station)
+	} else {
+		status, err = n.gatherURL(ctx, n.formatURL(n.ObservationPath, station))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.ObservationCacheTTL > 0 {
+		n.cacheObservation(station, status)
+	}
+	return []*Status{status}, nil
 }
 
-type ApiValue struct {
-	UnitCode       string  `json:"unitCode"`
-	Value          float64 `json:"value"`
-	QualityControl string  `json:"qualityControl"`
+// sortByTimestamp sorts the observations by ascending timestamp, as the API
+// returns the newest observation first. Observations with an invalid
+// timestamp are sorted first.
+func sortByTimestamp(statuses []*Status) {
+	timestamps := make(map[*Status]time.Time, len(statuses))
+	for _, status := range statuses {
+		tm, _ := parseTimestamp(status.Timestamp)
+		timestamps[status] = tm
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return timestamps[statuses[i]].Before(timestamps[statuses[j]])
+	})
 }
 
-type Status struct {
-	Temperature        ApiValue `json:"temperature"`
-	Humidity           ApiValue `json:"relativeHumidity"`
-	BarometricPressure ApiValue `json:"barometricPressure"`
-	Visibility         ApiValue `json:"visibility"`
-	WindSpeed          ApiValue `json:"windSpeed"`
-	WindDirection      ApiValue `json:"windDirection"`
-	Dewpoint           ApiValue `json:"dewpoint"`
-	Timestamp          string   `json:"timestamp"`
+// validObservationPath checks that the path contains exactly one %s
+// placeholder for the station and no other formatting directives.
+func validObservationPath(path string) bool {
+	return strings.Count(path, "%") == 1 && strings.Count(path, "%s") == 1
 }
 
-func gatherWeatherURL(r io.Reader) (*Status, error) {
-	dec := json.NewDecoder(r)
-	status := &Status{}
-	if err := dec.Decode(status); err != nil {
-		return nil, fmt.Errorf("error while decoding JSON response: %s", err)
+// checkPathPlaceholders returns an error if the path contains a named
+// placeholder other than {units}.
+func checkPathPlaceholders(option, path string) error {
+	for _, placeholder := range pathPlaceholderPattern.FindAllString(path, -1) {
+		if placeholder != unitsPlaceholder {
+			return fmt.Errorf("%s contains unknown placeholder %s: %s", option, placeholder, path)
+		}
 	}
-	return status, nil
+	return nil
 }
 
-func (n *NOAAWeatherAPI) UnitConversion(value ApiValue) float64 {
+// statsFor returns the counters of the station; statsLock must be held.
+func (n *NOAAWeatherAPI) statsFor(station string) *stationStats {
+	stats, ok := n.stats[station]
+	if !ok {
+		stats = &stationStats{}
+		n.stats[station] = stats
+	}
+	return stats
+}
 
-	switch value.UnitCode {
-	case "wmoUnit:degC":
-		if n.Units == "imperial" {
-			return value.Value*9.0/5.0 + 32
-		} else {
-			return value.Value
-		}
-	case "wmoUnit:km_h-1":
-		if n.Units == "imperial" {
-			return value.Value / 1.609
-		} else {
-			return value.Value
-		}
-	case "wmoUnit:m":
-		if n.Units == "imperial" {
-			return value.Value / 1609.0
-		} else {
-			return value.Value
-		}
-	default:
-		return value.Value
+// clampHumidity limits the relative humidity to [0, 100]. Values slightly
+// out of range are caused by rounding in the API's calculation.
+func (n *NOAAWeatherAPI) clampHumidity(station string, humidity float64) float64 {
+	clamped := math.Max(0, math.Min(100, humidity))
+	if clamped == humidity {
+		return humidity
 	}
+
+	n.statsLock.Lock()
+	n.statsFor(station).humidityClamped++
+	n.statsLock.Unlock()
+	n.Log.Warnf("Clamped relative humidity %v of station %s to %v", humidity, station, clamped)
+	return clamped
 }
 
-func (n *NOAAWeatherAPI) GatherWeather(acc telegraf.Accumulator, status *Status) {
+func (n *NOAAWeatherAPI) addStats(acc telegraf.Accumulator, station string, duration time.Duration, err error) {
+	n.statsLock.Lock()
+	stats := n.statsFor(station)
+	stats.requests++
+	if err != nil {
+		stats.errors++
+	}
 	fields := map[string]interface{}{
-		"pressure":     status.BarometricPressure.Value,
-		"dewpoint":     status.Dewpoint.Value,
-		"temperature":  n.UnitConversion(status.Temperature),
-		"humidity":     status.Humidity.Value,
-		"visibility":   n.UnitConversion(status.Visibility),
-		"wind_degrees": status.WindDirection.Value,
-		"wind_speed":   n.UnitConversion(status.WindSpeed),
+		"request_duration_ms": float64(duration) / float64(time.Millisecond),
+		"requests_total":      stats.requests,
+		"errors_total":        stats.errors,
 	}
-	tags := map[string]string{
-		"station": "KSUA",
+	if n.ClampHumidity {
+		fields["humidity_clamped_total"] = stats.humidityClamped
+	}
+	if n.SanityChecks {
+		fields["sanity_dropped_total"] = stats.sanityDropped
 	}
+	n.statsLock.Unlock()
 
-	layout := "2006-01-02T15:04:05Z07:00"
-	tm, err := time.Parse(layout, status.Timestamp)
-	if err != nil {
-		fmt.Errorf("%s", err)
-	} else {
-		acc.AddFields("noaa_weather", fields, tags, tm)
+	tags := map[string]string{
+		n.StationTagKey: station,
 	}
+	acc.AddFields("noaa_weather_internal", fields, tags)
 }
 
-func init() {
-	inputs.Add("noaa_weather_api", func() telegraf.Input {
-		tmout := config.Duration(defaultResponseTimeout)
-		return &NOAAWeatherAPI{
-			ResponseTimeout: tmout,
-			BaseURL:         defaultBaseURL,
-		}
-	})
+// setNow replaces the clock of the plugin, allowing tests to control time.
+func (n *NOAAWeatherAPI) setNow(now func() time.Time) {
+	n.now = now
 }
 
-func (n *NOAAWeatherAPI) Init() error {
-	var err error
-	n.baseParsedURL, err = url.Parse(n.BaseURL)
-	if err != nil {
-		return err
-	}
+// setSleep replaces the function used for all waits, allowing tests to
+// advance their clock instead.
+func (n *NOAAWeatherAPI) setSleep(sleep func(context.Context, time.Duration) error) {
+	n.sleep = sleep
+}
 
-	n.client = n.createHTTPClient()
+// sleepContext waits for the duration, returning the error of the context
+// if it is cancelled before.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	switch n.Units {
-	case "imperial", "metric":
-	case "":
-		n.Units = defaultUnits
-	default:
-		return fmt.Errorf("unknown units: %s", n.Units)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	return nil
+// Tags set by the plugin itself which cannot be used as station_tag_key.
+var reservedTagKeys = map[string]bool{
+	"station_name":   true,
+	"pressure_trend": true,
+	"derived":        true,
+	"name":           true,
+	"time_zone":      true,
+	"county":         true,
+	"state":          true,
+	"active":         true,
+	"unit_system":    true,
+	"source":         true,
 }
 
-func (n *NOAAWeatherAPI) formatURL(path string, station_id string) string {
+// Networks to dial for the ip_version option, "auto" dials either.
+var ipNetworks = map[string]string{
+	"ipv4": "tcp4",
+	"ipv6": "tcp6",
+}
 
-	v := url.Values{
-		"require_qc": []string{"false"},
+func (n *NOAAWeatherAPI) createHTTPClient() (*http.Client, error) {
+	if n.ResponseTimeout == 0 {
+		n.ResponseTimeout = config.Duration(defaultResponseTimeout)
+	}
+
+	tlsCfg, err := n.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if n.TLSCADir != "" {
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{}
+		}
+		// The authorities of tls_ca_dir are trusted in addition to the
+		// ones of the system unless tls_ca replaces those.
+		if tlsCfg.RootCAs == nil {
+			if tlsCfg.RootCAs, err = x509.SystemCertPool(); err != nil {
+				tlsCfg.RootCAs = x509.NewCertPool()
+			}
+		}
+		if err := loadCADir(tlsCfg.RootCAs, n.TLSCADir); err != nil {
+			return nil, err
+		}
+	}
+
+	if n.Transport != nil {
+		transport := n.Transport
+		if tlsCfg != nil {
+			t, ok := transport.(*http.Transport)
+			if !ok {
+				return nil, fmt.Errorf("TLS options cannot be applied to a transport of type %T", transport)
+			}
+			t = t.Clone()
+			t.TLSClientConfig = tlsCfg
+			transport = t
+		}
+		return &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(n.ResponseTimeout),
+		}, nil
+	}
+
+	dialer := &net.Dialer{
+		Timeout: time.Duration(n.DialTimeout),
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        n.MaxIdleConns,
+		MaxIdleConnsPerHost: n.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(n.IdleConnTimeout),
+		TLSClientConfig:     tlsCfg,
+	}
+	if network, ok := ipNetworks[n.IPVersion]; ok {
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
 	}
 
-	relative := &url.URL{
-		Path:     fmt.Sprintf(path, url.PathEscape(station_id)),
-		RawQuery: v.Encode(),
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(n.ResponseTimeout),
 	}
 
-	return n.baseParsedURL.ResolveReference(relative).String()
+	return client, nil
+}
+
+// loadCADir adds the certificates of all PEM files in the directory to the
+// pool.
+func loadCADir(pool *x509.CertPool, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("tls_ca_dir %s contains no PEM files", dir)
+	}
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading certificate authority %s failed: %s", file, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", file)
+		}
+	}
+	return nil
+}
+
+func (n *NOAAWeatherAPI) gatherURL(ctx context.Context, addr string) (*Status, error) {
+	var status *Status
+	err := n.withFailover(ctx, addr, func(addr string) error {
+		return n.retryTruncated(ctx, func() error {
+			resp, err := n.requestObservation(ctx, http.MethodGet, addr)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			status, err = gatherWeatherURL(resp.Body, n.StrictDecoding)
+			if err != nil {
+				return err
+			}
+			status.applyQC(n.QCJSONKey)
+			return nil
+		})
+	})
+	return status, err
+}
+
+// gatherFile reports the observation stored in the file, tagged with the
+// station referenced by the observation.
+func (n *NOAAWeatherAPI) gatherFile(acc telegraf.Accumulator) error {
+	f, err := os.Open(n.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	status, err := gatherWeatherURL(f, n.StrictDecoding)
+	if err != nil {
+		return fmt.Errorf("reading %s failed: %s", n.File, err)
+	}
+	status.applyQC(n.QCJSONKey)
+	n.GatherWeather(acc, status)
+	return nil
+}
+
+func (n *NOAAWeatherAPI) gatherHistoryURL(ctx context.Context, addr string) (*History, error) {
+	var history *History
+	err := n.retryTruncated(ctx, func() error {
+		resp, err := n.request(ctx, addr, geoJSONMediaTypes)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		history, err = gatherHistory(resp.Body, n.StrictDecoding)
+		if err != nil {
+			return err
+		}
+		for i := range history.Features {
+			history.Features[i].Properties.applyQC(n.QCJSONKey)
+		}
+		return nil
+	})
+	return history, err
+}
+
+// statusError is returned for responses with a status other than 200 OK.
+type statusError struct {
+	addr   string
+	code   int
+	status string
+
+	// Problem reported by the API in the body, if any
+	problem *Problem
+}
+
+func (e *statusError) Error() string {
+	msg := fmt.Sprintf("%s returned HTTP status %s", e.addr, e.status)
+	if e.problem != nil {
+		if e.problem.Title != "" {
+			msg += ": " + e.problem.Title
+		}
+		if e.problem.Detail != "" {
+			msg += ": " + e.problem.Detail
+		}
+	}
+	return msg
+}
+
+// Problem is the RFC 7807 problem details body the API returns with error
+// responses.
+type Problem struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// decodeProblem returns the problem details of an error response, or nil
+// if the response does not contain any.
+func decodeProblem(resp *http.Response) *Problem {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/problem+json" {
+		return nil
+	}
+
+	problem := &Problem{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxProblemSize)).Decode(problem); err != nil {
+		return nil
+	}
+	if problem.Title == "" && problem.Detail == "" {
+		return nil
+	}
+	return problem
+}
+
+// limitedBody fails reading a response body larger than max_body_size
+// instead of silently truncating it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// Read one byte beyond the limit to detect oversized bodies.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n - 1, errBodyTooLarge
+	}
+	return n, err
+}
+
+var errBodyTooLarge = errors.New("response body exceeds max_body_size")
+
+// Media types accepted for JSON-LD endpoints, such as the latest
+// observation, and for GeoJSON endpoints returning feature collections. The
+// first entry is sent in the Accept header; proxies may normalize the
+// response to plain JSON.
+var (
+	ldJSONMediaTypes  = []string{"application/ld+json", "application/json"}
+	geoJSONMediaTypes = []string{"application/geo+json", "application/json"}
+)
+
+// request performs a GET against addr accepting the first of the media
+// types, see requestAccept.
+func (n *NOAAWeatherAPI) request(ctx context.Context, addr string, mediaTypes []string) (*http.Response, error) {
+	accept := mediaTypes[0]
+	if n.ServerSideUnits != "" {
+		accept += "; units=" + n.ServerSideUnits
+	}
+	return n.requestAccept(ctx, http.MethodGet, addr, accept, mediaTypes)
+}
+
+// requestObservation requests an observation with the method, sending
+// accept_header as Accept header and expecting a response of
+// expected_content_type if set.
+func (n *NOAAWeatherAPI) requestObservation(ctx context.Context, method string, addr string) (*http.Response, error) {
+	mediaTypes := ldJSONMediaTypes
+	if n.ExpectedContentType != "" {
+		mediaTypes = []string{n.ExpectedContentType}
+	}
+	accept := n.AcceptHeader
+	if accept == "" {
+		accept = mediaTypes[0]
+		if n.ServerSideUnits != "" {
+			accept += "; units=" + n.ServerSideUnits
+		}
+	}
+	return n.requestAccept(ctx, method, addr, accept, mediaTypes)
+}
+
+// requestAccept performs a request with the method against addr with the
+// Accept header and checks that the response is successful and of one of
+// the given media types. The caller must close the body. The request is
+// aborted when the context is cancelled.
+func (n *NOAAWeatherAPI) requestAccept(ctx context.Context, method string, addr string, accept string, mediaTypes []string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if n.TraceRequests {
+		ctx = n.traceContext(ctx, addr)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", accept)
+	req.Header.Add("User-Agent", n.UserAgent)
+	req.Header.Add("Accept-Language", n.AcceptLanguage)
+	if n.BearerToken != "" {
+		token, err := os.ReadFile(n.BearerToken)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	if n.Username != "" || n.Password != "" {
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+	if !n.takeRequest() {
+		return nil, errBudgetExhausted
+	}
+	resp, err := n.doWithRetry(req)
+	if ctx.Err() != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request to %s: %s", addr, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, &statusError{addr: addr, code: resp.StatusCode, status: resp.Status, problem: decodeProblem(resp)}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	for _, accepted := range mediaTypes {
+		if mediaType == accepted {
+			resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: int64(n.MaxBodySize)}
+			return resp, nil
+		}
+	}
+
+	// Redirects might end up at an HTML error page, report where we ended up
+	// and what it said.
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodySnippet))
+	return nil, fmt.Errorf("%s returned unexpected content type %s, expected one of %s: %q",
+		resp.Request.URL, mediaType, strings.Join(mediaTypes, ", "), strings.TrimSpace(string(snippet)))
+}
+
+type ApiValue struct {
+	UnitCode       string   `json:"unitCode"`
+	Value          *float64 `json:"value"`
+	QualityControl string   `json:"qualityControl"`
+
+	// flag of the "qc" key, see qc_json_key
+	qc string
+
+	// error for a key unknown to the value, reported with strict_decoding
+	unknownKey error
+}
+
+type Status struct {
+	Temperature        ApiValue `json:"temperature"`
+	Humidity           ApiValue `json:"relativeHumidity"`
+	BarometricPressure ApiValue `json:"barometricPressure"`
+	Visibility         ApiValue `json:"visibility"`
+	WindSpeed          ApiValue `json:"windSpeed"`
+	WindDirection      ApiValue `json:"windDirection"`
+	Dewpoint           ApiValue `json:"dewpoint"`
+	Timestamp          string   `json:"timestamp"`
+	RawMessage         string   `json:"rawMessage"`
+	Station            string   `json:"station"`
+	HeatIndex          ApiValue `json:"heatIndex"`
+	WindChill          ApiValue `json:"windChill"`
+	SeaLevelPressure   ApiValue `json:"seaLevelPressure"`
+
+	CloudLayers []CloudLayer `json:"cloudLayers"`
+}
+
+// values returns the measured values of the observation keyed by field name.
+func (s *Status) values() map[string]ApiValue {
+	return map[string]ApiValue{
+		"pressure":     s.BarometricPressure,
+		"dewpoint":     s.Dewpoint,
+		"humidity":     s.Humidity,
+		"wind_degrees": s.WindDirection,
+		"temperature":  s.Temperature,
+		"visibility":   s.Visibility,
+		"wind_speed":   s.WindSpeed,
+	}
+}
+
+// Fields converted to the configured unit system, the others are reported
+// as returned by the API.
+var convertedFields = map[string]bool{
+	"temperature": true,
+	"heat_index":  true,
+	"wind_chill":  true,
+	"visibility":  true,
+	"wind_speed":  true,
+}
+
+// History is the GeoJSON feature collection returned by the station
+// observations endpoint.
+type History struct {
+	Features []struct {
+		Properties Status `json:"properties"`
+	} `json:"features"`
+}
+
+// errTruncatedResponse is reported for responses ending in the middle of
+// the JSON document, e.g. due to a dropped connection.
+var errTruncatedResponse = errors.New("truncated or partial JSON response")
+
+func decodeError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("error while decoding JSON response: %w", errTruncatedResponse)
+	}
+	return fmt.Errorf("error while decoding JSON response: %s", err)
+}
+
+// gatherWeatherURL decodes an observation; with strict set, fields unknown
+// to Status are reported as error. Observations in the GeoJSON feature form
+// are unwrapped from their properties.
+func gatherWeatherURL(r io.Reader, strict bool) (*Status, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, decodeError(err)
+	}
+
+	var feature struct {
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &feature); err != nil {
+		return nil, decodeError(err)
+	}
+	if len(feature.Properties) > 0 && string(feature.Properties) != "null" {
+		raw = feature.Properties
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	status := &Status{}
+	if err := dec.Decode(status); err != nil {
+		return nil, decodeError(err)
+	}
+	if strict {
+		if err := status.unknownKey(); err != nil {
+			return nil, decodeError(err)
+		}
+	}
+	return status, nil
+}
+
+func gatherHistory(r io.Reader, strict bool) (*History, error) {
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	history := &History{}
+	if err := dec.Decode(history); err != nil {
+		return nil, decodeError(err)
+	}
+	if strict {
+		for i := range history.Features {
+			if err := history.Features[i].Properties.unknownKey(); err != nil {
+				return nil, decodeError(err)
+			}
+		}
+	}
+	return history, nil
+}
+
+// reportUnknownUnit adds an error for a unit code UnitConversion cannot
+// handle. Each code is only reported once per gather.
+func (n *NOAAWeatherAPI) reportUnknownUnit(acc telegraf.Accumulator, field string, unitCode string) {
+	n.unknownUnitsLock.Lock()
+	seen := n.unknownUnits[unitCode]
+	n.unknownUnits[unitCode] = true
+	n.unknownUnitsLock.Unlock()
+
+	if !seen {
+		acc.AddError(fmt.Errorf("unknown unit code %q of field %s, reporting the value unconverted", unitCode, field))
+	}
+}
+
+// convertMeters converts a length in meters to the given unit, one of
+// "m", "km", "mi" or "ft".
+func convertMeters(v float64, unit string) float64 {
+	switch unit {
+	case "km":
+		return v / 1000.0
+	case "mi":
+		return v / 1609.0
+	case "ft":
+		return v / 0.3048
+	default:
+		return v
+	}
+}
+
+// GatherWeather reports the observation, tagged with the station the
+// observation references.
+func (n *NOAAWeatherAPI) GatherWeather(acc telegraf.Accumulator, status *Status) {
+	n.gatherWeather(acc, "", status)
+}
+
+// gatherWeather reports the observation of the station. An empty station
+// falls back to the station referenced by the observation itself.
+func (n *NOAAWeatherAPI) gatherWeather(acc telegraf.Accumulator, station string, status *Status) {
+	if station == "" {
+		station = lastPathSegment(status.Station)
+	}
+
+	fields := make(map[string]interface{})
+
+	// Null values are reported by the API for readings the station did not
+	// provide; those are skipped instead of being reported as zero.
+	values := status.values()
+	missing := 0
+	for name, value := range values {
+		if value.Value == nil {
+			missing++
+			continue
+		}
+		if !n.sane(station, name, *value.Value) {
+			continue
+		}
+		n.addValue(acc, fields, name, value)
+	}
+	derived := n.addDerivedTemperatures(acc, fields, status)
+	if n.ComputeSeaLevelPressure {
+		n.addSeaLevelPressure(acc, fields, station, status)
+	}
+	n.addCeiling(fields, status)
+	if n.ComputeAbsoluteHumidity {
+		if ah, ok := computeAbsoluteHumidity(status.Temperature, status.Humidity); ok {
+			fields["absolute_humidity"] = ah
+		}
+	}
+	if degrees, ok := fields["wind_degrees"].(float64); ok && n.IncludeWindCardinal {
+		fields["wind_cardinal"] = windCardinal(degrees)
+	}
+	if n.WindComponents {
+		speed, hasSpeed := fields["wind_speed"].(float64)
+		degrees, hasDegrees := fields["wind_degrees"].(float64)
+		if hasSpeed && hasDegrees {
+			fields["wind_u"], fields["wind_v"] = windComponents(speed, degrees)
+			if n.WindComponentsOnly {
+				delete(fields, "wind_speed")
+				delete(fields, "wind_degrees")
+			}
+		}
+	}
+	if humidity, ok := fields["humidity"].(float64); ok && n.ClampHumidity {
+		fields["humidity"] = n.clampHumidity(station, humidity)
+	}
+
+	if status.Timestamp == "" && len(fields) == 0 {
+		acc.AddError(fmt.Errorf("station %s returned no observation", station))
+		return
+	}
+	if n.IncludeQCRequired {
+		fields["qc_required"] = n.RequireQC
+	}
+
+	tags := n.stationTags(station)
+	if derived {
+		tags["derived"] = "true"
+	}
+	if n.IncludeUnitsTag {
+		tags["unit_system"] = n.unitSystem()
+	}
+
+	if n.IncludeRawMessage {
+		fields["metar"] = status.RawMessage
+	}
+
+	if n.CollectCompleteness {
+		fields["completeness_ratio"] = float64(len(values)-missing) / float64(len(values))
+		fields["missing_fields"] = missing
+	}
+
+	tm, err := parseTimestamp(status.Timestamp)
+	if err != nil {
+		acc.AddError(fmt.Errorf("station %s returned invalid timestamp: %s", station, err))
+		if !n.UseNowOnParseErr {
+			return
+		}
+		tm = n.now()
+	}
+
+	if n.MaxObservationAge > 0 {
+		if age := n.now().Sub(tm); age > time.Duration(n.MaxObservationAge) {
+			n.Log.Warnf("Skipping observation of station %s from %s, it is %s old", station, tm.Format(time.RFC3339), age.Round(time.Second))
+			return
+		}
+	}
+	if n.IncludeAge {
+		fields["observation_age_seconds"] = n.observationAge(station, tm)
+	}
+	if n.IncludeHourTag {
+		tags["obs_hour"] = tm.UTC().Format("15")
+	}
+	observed := tm
+
+	warmup := n.warmup(station)
+	if pressure, ok := fields["pressure"].(float64); ok && n.IncludePressureTendency {
+		tendency, ok := n.pressureTendency(station, pressure, observed)
+		switch {
+		case warmup && n.SkipWarmupFields:
+			n.Log.Debugf("Omitting pressure_tendency of station %s during warmup", station)
+		case ok:
+			fields["pressure_tendency"] = tendency
+			tags["pressure_trend"] = pressureTrend(tendency)
+		}
+	}
+
+	if n.TimestampSource == "collection" {
+		tm = n.now()
+	}
+
+	if n.RoundDecimals != nil {
+		roundFields(fields, *n.RoundDecimals)
+	}
+
+	n.recordComparedValues(station, fields)
+
+	fields = n.filterFields(fields)
+	if len(fields) == 0 {
+		return
+	}
+	n.markReported()
+
+	if n.DeltaOnly {
+		fields = n.changedFields(station, fields)
+		if len(fields) == 0 {
+			return
+		}
+	}
+
+	if n.SkipDuplicateObservations && n.duplicateObservation(station, observed) {
+		n.Log.Debugf("Skipping observation of station %s from %s, it was reported before", station, observed.Format(time.RFC3339))
+		return
+	}
+
+	if n.FieldPrefix != "" {
+		prefixed := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			prefixed[n.FieldPrefix+k] = v
+		}
+		fields = prefixed
+	}
+
+	if n.MetricLayout == "narrow" {
+		for k, v := range fields {
+			acc.AddFields(n.ObservationMeasurement+"_"+k, map[string]interface{}{"value": v}, tags, tm)
+		}
+		return
+	}
+
+	acc.AddFields(n.ObservationMeasurement, fields, tags, tm)
+}
+
+// unitSystem returns the unit system the observations are reported in;
+// values reported as returned by the API are in SI units.
+func (n *NOAAWeatherAPI) unitSystem() string {
+	switch {
+	case n.ServerSideUnits == "us":
+		return "imperial"
+	case n.ServerSideUnits == "si" || n.Units == "none":
+		return "si"
+	default:
+		return n.Units
+	}
+}
+
+// roundFields rounds the floating point fields to the given number of
+// decimal places.
+func roundFields(fields map[string]interface{}, decimals int) {
+	scale := math.Pow(10, float64(decimals))
+	for k, v := range fields {
+		if f, ok := v.(float64); ok {
+			fields[k] = math.Round(f*scale) / scale
+		}
+	}
+}
+
+// observationAge returns the age of the observation in seconds. Observations
+// from the future are reported with an age of zero.
+func (n *NOAAWeatherAPI) observationAge(station string, tm time.Time) float64 {
+	age := n.now().Sub(tm).Seconds()
+	if age >= 0 {
+		return age
+	}
+
+	n.stateLock.Lock()
+	state := n.stateFor(station)
+	logged := state.futureLogged
+	state.futureLogged = true
+	n.stateLock.Unlock()
+
+	if !logged {
+		n.Log.Warnf("Station %s reported an observation from the future (%s), check its clock", station, tm.Format(time.RFC3339))
+	}
+	return 0
+}
+
+// filterFields applies fields_include and fields_exclude to the fields of
+// an observation.
+func (n *NOAAWeatherAPI) filterFields(fields map[string]interface{}) map[string]interface{} {
+	if len(n.FieldsInclude) > 0 {
+		included := make(map[string]interface{}, len(n.FieldsInclude))
+		for _, name := range n.FieldsInclude {
+			if v, ok := fields[name]; ok {
+				included[name] = v
+			}
+		}
+		fields = included
+	}
+	for _, name := range n.FieldsExclude {
+		delete(fields, name)
+	}
+	return fields
+}
+
+// knownFields returns the names of all fields the plugin may report.
+func knownFields() map[string]bool {
+	known := map[string]bool{
+		"wind_cardinal":           true,
+		"pressure_tendency":       true,
+		"metar":                   true,
+		"completeness_ratio":      true,
+		"missing_fields":          true,
+		"observation_age_seconds": true,
+		"absolute_humidity":       true,
+		"ceiling":                 true,
+		"ceiling_unit":            true,
+		"qc_required":             true,
+		"wind_u":                  true,
+		"wind_v":                  true,
+	}
+	names := []string{"heat_index", "wind_chill", "sea_level_pressure"}
+	for name := range (&Status{}).values() {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		known[name] = true
+		known[name+"_unit"] = true
+		known[name+"_raw"] = true
+		known[name+"_raw_unit"] = true
+	}
+	return known
+}
+
+// addValue adds the non-null value to the fields, converted to the
+// configured unit system.
+func (n *NOAAWeatherAPI) addValue(acc telegraf.Accumulator, fields map[string]interface{}, name string, value ApiValue) {
+	if n.EmitUnitMetadata && value.UnitCode != "" {
+		n.recordUnit(name, value.UnitCode)
+	}
+
+	if n.convertible(name, value) {
+		if _, ok := n.conversions[value.UnitCode]; !ok {
+			n.reportUnknownUnit(acc, name, value.UnitCode)
+		}
+	}
+	fields[name] = n.convertValue(name, value)
+	if n.Units == "none" && value.UnitCode != "" {
+		fields[name+"_unit"] = value.UnitCode
+	}
+	if n.IncludeRawValues {
+		fields[name+"_raw"] = *value.Value
+		if value.UnitCode != "" {
+			fields[name+"_raw_unit"] = value.UnitCode
+		}
+	}
+}
+
+// convertible returns true if the value of the field is converted to the
+// configured unit system. Values converted by the server are reported as
+// returned, overrides apply to all fields with the unit code.
+func (n *NOAAWeatherAPI) convertible(name string, value ApiValue) bool {
+	switch {
+	case n.ServerSideUnits != "" || n.Units == "none":
+		return false
+	case n.overridden[value.UnitCode]:
+		return true
+	default:
+		return convertedFields[name]
+	}
+}
+
+// convertValue returns the non-null value of the field converted to the
+// configured unit system.
+func (n *NOAAWeatherAPI) convertValue(name string, value ApiValue) float64 {
+	switch {
+	case !n.convertible(name, value):
+		return *value.Value
+	case name == "visibility" && n.VisibilityUnit != "" && value.UnitCode == "wmoUnit:m" && !n.overridden[value.UnitCode]:
+		return convertMeters(*value.Value, n.VisibilityUnit)
+	default:
+		return n.UnitConversion(value)
+	}
+}
+
+// pressureTendency records the pressure of the station in Pa and returns
+// its change in hPa since the previous observation. No tendency is
+// available for the first observation of a station or if the observation
+// is not newer than the previous one, e.g. when served from the cache.
+func (n *NOAAWeatherAPI) pressureTendency(station string, pressure float64, timestamp time.Time) (float64, bool) {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	if !timestamp.After(state.lastPressureTime) {
+		return 0, false
+	}
+	last := state.lastPressure
+	state.lastPressure = &pressure
+	state.lastPressureTime = timestamp
+	if last == nil {
+		return 0, false
+	}
+	return (pressure - *last) / 100, true
+}
+
+// warmup reports whether this is the first observation of the station,
+// for which fields depending on previous gathers are not available.
+func (n *NOAAWeatherAPI) warmup(station string) bool {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	warmup := !state.warmedUp
+	state.warmedUp = true
+	return warmup
+}
+
+// changedFields returns the fields whose value differs from the previous
+// observation of the station. All fields of the first observation are
+// returned.
+func (n *NOAAWeatherAPI) changedFields(station string, fields map[string]interface{}) map[string]interface{} {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	changed := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if last, ok := state.lastFields[k]; !ok || last != v {
+			changed[k] = v
+		}
+	}
+	state.lastFields = fields
+	return changed
+}
+
+// duplicateObservation reports whether an observation of the station with
+// the same or a later timestamp was reported before, e.g. by a previous
+// gather of an overlapping history window, and records the timestamp as
+// the last reported one otherwise.
+func (n *NOAAWeatherAPI) duplicateObservation(station string, timestamp time.Time) bool {
+	n.stateLock.Lock()
+	defer n.stateLock.Unlock()
+
+	state := n.stateFor(station)
+	if !timestamp.After(state.lastReported) {
+		return true
+	}
+	state.lastReported = timestamp
+	return false
+}
+
+func pressureTrend(tendency float64) string {
+	switch {
+	case tendency >= pressureSteadyThreshold:
+		return "rising"
+	case tendency <= -pressureSteadyThreshold:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// stateFor returns the state of the station; stateLock must be held.
+func (n *NOAAWeatherAPI) stateFor(station string) *stationState {
+	state, ok := n.state[station]
+	if !ok {
+		state = &stationState{}
+		n.state[station] = state
+	}
+	return state
+}
+
+// stationTags returns the tags identifying the station of an observation.
+func (n *NOAAWeatherAPI) stationTags(station string) map[string]string {
+	tags := map[string]string{
+		n.StationTagKey: station,
+	}
+	if len(n.StationLabels) > 0 {
+		if label, ok := n.StationLabels[station]; ok {
+			tags["station_name"] = label
+		} else if !n.LabelRequired {
+			tags["station_name"] = station
+		}
+	}
+	if n.IncludeStationMetadata {
+		if meta := n.cachedMetadata(station); meta != nil {
+			for k, v := range meta.Tags() {
+				tags[k] = v
+			}
+		}
+	}
+	return tags
+}
+
+var compassPoints = []string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// windCardinal returns the 16-point compass direction of the wind direction
+// in degrees. Each point covers 22.5 degrees centered on its direction.
+func windCardinal(degrees float64) string {
+	sector := math.Mod(degrees+11.25, 360)
+	if sector < 0 {
+		sector += 360
+	}
+	return compassPoints[int(sector/22.5)%len(compassPoints)]
+}
+
+// windComponents returns the zonal (u) and meridional (v) components of the
+// wind blowing from the direction in degrees. Following the meteorological
+// convention, u is positive towards the east and v towards the north.
+func windComponents(speed, degrees float64) (u, v float64) {
+	rad := degrees * math.Pi / 180
+	return -speed * math.Sin(rad), -speed * math.Cos(rad)
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range timestampLayouts {
+		tm, err := time.Parse(layout, value)
+		if err == nil {
+			return tm, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+func init() {
+	inputs.Add("noaa_weather_api", func() telegraf.Input {
+		tmout := config.Duration(defaultResponseTimeout)
+		return &NOAAWeatherAPI{
+			ResponseTimeout: tmout,
+			BaseURL:         defaultBaseURL,
+			SourceTag:       defaultSourceTag,
+		}
+	})
+}
+
+func (n *NOAAWeatherAPI) Init() error {
+	var err error
+	n.baseParsedURL, err = url.Parse(n.BaseURL)
+	if err != nil {
+		return err
+	}
+	if err := n.initFallbackURLs(); err != nil {
+		return err
+	}
+
+	if err := validateStations(n.StationID); err != nil {
+		return err
+	}
+	if !n.AllowDuplicateStations {
+		var duplicates []string
+		n.StationID, duplicates = dedupStations(n.StationID)
+		if len(duplicates) > 0 {
+			n.Log.Warnf("Ignoring duplicate stations: %s", strings.Join(duplicates, ", "))
+		}
+	}
+
+	switch {
+	case n.ObservationPath == "":
+		n.ObservationPath = defaultObservationPath
+	case !validObservationPath(n.ObservationPath):
+		return fmt.Errorf("observation_path must contain exactly one %%s placeholder: %s", n.ObservationPath)
+	}
+	if n.BatchRequests && (n.HistoryDuration > 0 || n.SecondaryObservationPath != "") {
+		return fmt.Errorf("batch_requests cannot be combined with history_duration or secondary_observation_path")
+	}
+	if n.SecondaryObservationPath != "" && !validObservationPath(n.SecondaryObservationPath) {
+		return fmt.Errorf("secondary_observation_path must contain exactly one %%s placeholder: %s", n.SecondaryObservationPath)
+	}
+	if err := checkPathPlaceholders("observation_path", n.ObservationPath); err != nil {
+		return err
+	}
+	if err := checkPathPlaceholders("secondary_observation_path", n.SecondaryObservationPath); err != nil {
+		return err
+	}
+	switch n.ProbeMethod {
+	case "":
+		n.ProbeMethod = http.MethodGet
+	case http.MethodGet, http.MethodHead:
+	default:
+		return fmt.Errorf("unknown probe_method: %s", n.ProbeMethod)
+	}
+	if n.ExpectedContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(n.ExpectedContentType)
+		if err != nil {
+			return fmt.Errorf("invalid expected_content_type: %s", err)
+		}
+		n.ExpectedContentType = mediaType
+	}
+
+	if n.ResponseTimeout < 0 {
+		return fmt.Errorf("response_timeout must not be negative")
+	}
+	if n.DialTimeout < 0 {
+		return fmt.Errorf("dial_timeout must not be negative")
+	}
+	switch n.IPVersion {
+	case "":
+		n.IPVersion = "auto"
+	case "auto", "ipv4", "ipv6":
+	default:
+		return fmt.Errorf("unknown ip_version: %s", n.IPVersion)
+	}
+	switch {
+	case n.MaxParallel == 0:
+		n.MaxParallel = defaultMaxParallel
+	case n.MaxParallel < 0:
+		return fmt.Errorf("max_parallel must not be negative")
+	}
+	if n.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if n.RetryBaseDelay == 0 {
+		n.RetryBaseDelay = config.Duration(defaultRetryBaseDelay)
+	}
+	if n.RetryMaxDelay == 0 {
+		n.RetryMaxDelay = config.Duration(defaultRetryMaxDelay)
+	}
+	if n.ScheduleJitter < 0 {
+		return fmt.Errorf("schedule_jitter must not be negative")
+	}
+	if err := n.initRetryOn(); err != nil {
+		return err
+	}
+	if n.RateLimit < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+	n.initRateLimiter()
+	if n.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold must not be negative")
+	}
+	if n.CircuitCooldown == 0 {
+		n.CircuitCooldown = config.Duration(defaultCircuitCooldown)
+	}
+
+	if n.MaxIdleConns < 0 || n.MaxIdleConnsPerHost < 0 || n.IdleConnTimeout < 0 {
+		return fmt.Errorf("max_idle_conns, max_idle_conns_per_host and idle_conn_timeout must not be negative")
+	}
+	if n.MaxIdleConns == 0 {
+		n.MaxIdleConns = defaultMaxIdleConns
+	}
+	// All requests go to the same host, so keep a connection per parallel
+	// request around.
+	if n.MaxIdleConnsPerHost == 0 {
+		n.MaxIdleConnsPerHost = n.MaxParallel
+	}
+	if n.IdleConnTimeout == 0 {
+		n.IdleConnTimeout = config.Duration(defaultIdleConnTimeout)
+	}
+
+	if n.now == nil {
+		n.now = time.Now
+	}
+	if n.sleep == nil {
+		n.sleep = sleepContext
+	}
+	n.client, err = n.createHTTPClient()
+	if err != nil {
+		return err
+	}
+	n.stats = make(map[string]*stationStats)
+	n.metadata = make(map[string]*StationMetadata)
+	n.state = make(map[string]*stationState)
+	n.unknownUnits = make(map[string]bool)
+	n.sourceUnits = make(map[string]string)
+	n.compared = make(map[string]map[string]float64)
+
+	if n.HistoryStart != "" {
+		if n.HistoryDuration <= 0 {
+			return fmt.Errorf("history_start requires a positive history_duration")
+		}
+		n.historyStart, err = time.Parse(time.RFC3339, n.HistoryStart)
+		if err != nil {
+			return fmt.Errorf("invalid history_start: %s", err)
+		}
+	}
+
+	if err := n.initCompareStations(); err != nil {
+		return err
+	}
+
+	if n.WindComponentsOnly && !n.WindComponents {
+		return fmt.Errorf("wind_components_only requires wind_components")
+	}
+
+	switch n.Units {
+	case "imperial", "metric", "none":
+	case "":
+		n.Units = defaultUnits
+	default:
+		return fmt.Errorf("unknown units: %s", n.Units)
+	}
+	n.ObservationPath = strings.ReplaceAll(n.ObservationPath, unitsPlaceholder, n.Units)
+	n.SecondaryObservationPath = strings.ReplaceAll(n.SecondaryObservationPath, unitsPlaceholder, n.Units)
+
+	switch n.VisibilityUnit {
+	case "", "m", "km", "mi":
+	default:
+		return fmt.Errorf("unknown visibility_unit: %s", n.VisibilityUnit)
+	}
+
+	if n.BearerToken != "" && (n.Username != "" || n.Password != "") {
+		return fmt.Errorf("bearer_token and username/password are mutually exclusive")
+	}
+
+	switch n.ServerSideUnits {
+	case "", "us", "si":
+	default:
+		return fmt.Errorf("unknown server_side_units: %s", n.ServerSideUnits)
+	}
+
+	switch {
+	case n.MaxBodySize == 0:
+		n.MaxBodySize = config.Size(defaultMaxBodySize)
+	case n.MaxBodySize < 0:
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+
+	if n.AcceptLanguage == "" {
+		n.AcceptLanguage = defaultAcceptLanguage
+	}
+
+	if n.ObservationMeasurement == "" {
+		n.ObservationMeasurement = defaultObservationMeasurement
+	}
+	if n.ForecastMeasurement == "" {
+		n.ForecastMeasurement = defaultForecastMeasurement
+	}
+	if n.AlertMeasurement == "" {
+		n.AlertMeasurement = defaultAlertMeasurement
+	}
+	if n.GridpointMeasurement == "" {
+		n.GridpointMeasurement = defaultGridpointMeasurement
+	}
+
+	if n.StationTagKey == "" {
+		n.StationTagKey = "station"
+	}
+	if reservedTagKeys[n.StationTagKey] {
+		return fmt.Errorf("station_tag_key %q is reserved", n.StationTagKey)
+	}
+
+	switch n.TimestampSource {
+	case "":
+		n.TimestampSource = "observation"
+	case "observation", "collection":
+	default:
+		return fmt.Errorf("unknown timestamp_source: %s", n.TimestampSource)
+	}
+	if n.TimestampSource == "collection" && n.HistoryDuration > 0 {
+		return fmt.Errorf("timestamp_source %q cannot be combined with history_duration", n.TimestampSource)
+	}
+
+	if n.MaxRequestsPerInterval < 0 {
+		return fmt.Errorf("max_requests_per_interval must not be negative")
+	}
+
+	if n.GridpointRaw && len(n.GridpointParameters) == 0 {
+		return fmt.Errorf("gridpoint_parameters must be set with gridpoint_raw")
+	}
+
+	if n.RoundDecimals != nil && *n.RoundDecimals < 0 {
+		return fmt.Errorf("round_decimals must not be negative")
+	}
+
+	switch n.QCJSONKey {
+	case "":
+		n.QCJSONKey = qcKeyQualityControl
+	case qcKeyQualityControl, qcKeyShort:
+	default:
+		return fmt.Errorf("unknown qc_json_key: %s", n.QCJSONKey)
+	}
+
+	switch n.MetricLayout {
+	case "":
+		n.MetricLayout = "wide"
+	case "wide", "narrow":
+	default:
+		return fmt.Errorf("unknown metric_layout: %s", n.MetricLayout)
+	}
+
+	if err := n.initConversions(); err != nil {
+		return err
+	}
+
+	if err := n.initSanityLimits(); err != nil {
+		return err
+	}
+
+	known := knownFields()
+	for _, name := range append(append([]string{}, n.FieldsInclude...), n.FieldsExclude...) {
+		if !known[name] {
+			return fmt.Errorf("unknown field in fields_include or fields_exclude: %s", name)
+		}
+	}
+
+	if n.ZoneID != "" {
+		switch {
+		case n.MaxZoneStations == 0:
+			n.MaxZoneStations = defaultMaxZoneStations
+		case n.MaxZoneStations < 0:
+			return fmt.Errorf("max_zone_stations must not be negative")
+		}
+		if err := n.addZoneStations(); err != nil {
+			return err
+		}
+	}
+
+	if n.StationQuery != "" {
+		switch {
+		case n.StationQueryLimit == 0:
+			n.StationQueryLimit = defaultStationQueryLimit
+		case n.StationQueryLimit < 0:
+			return fmt.Errorf("station_query_limit must not be negative")
+		}
+		if err := n.addQueryStations(); err != nil {
+			return err
+		}
+	}
+
+	if n.StationFileRefresh < 0 {
+		return fmt.Errorf("station_file_refresh must not be negative")
+	}
+	if n.StationFile != "" {
+		n.staticStations = n.StationID
+		if err := n.loadStationFile(); err != nil {
+			return err
+		}
+	}
+
+	if n.File != "" && n.IncludeStationMetadata {
+		return fmt.Errorf("include_station_metadata is not supported with file")
+	}
+
+	if n.ComputeSeaLevelPressure && !n.IncludeStationMetadata {
+		return fmt.Errorf("compute_sea_level_pressure requires include_station_metadata")
+	}
+
+	if n.PrefetchMetadata {
+		if !n.IncludeStationMetadata {
+			return fmt.Errorf("prefetch_metadata requires include_station_metadata")
+		}
+		if err := n.prefetchMetadata(); err != nil {
+			return err
+		}
+	}
+
+	return n.initGridpoints()
+}
+
+// validateStations checks that every station identifier looks like one the
+// API accepts. Besides four letter ICAO identifiers the API serves mesonet
+// stations with longer alphanumeric identifiers, so only the character set
+// is enforced.
+func validateStations(stations []string) error {
+	var invalid []string
+	for _, station := range stations {
+		if !stationIDPattern.MatchString(station) {
+			invalid = append(invalid, fmt.Sprintf("%q", station))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid station_id: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// dedupStations removes repeated stations while preserving the order of
+// their first occurrence. The removed entries are returned as well.
+func dedupStations(stations []string) ([]string, []string) {
+	seen := make(map[string]bool, len(stations))
+	unique := make([]string, 0, len(stations))
+	var duplicates []string
+	for _, station := range stations {
+		if seen[station] {
+			duplicates = append(duplicates, station)
+			continue
+		}
+		seen[station] = true
+		unique = append(unique, station)
+	}
+	return unique, duplicates
+}
+
+// historyWindow returns the time range of observations to request when
+// collecting history.
+func (n *NOAAWeatherAPI) historyWindow() (time.Time, time.Time) {
+	if n.historyStart.IsZero() {
+		end := n.now()
+		return end.Add(-time.Duration(n.HistoryDuration)), end
+	}
+	return n.historyStart, n.historyStart.Add(time.Duration(n.HistoryDuration))
+}
+
+func (n *NOAAWeatherAPI) formatHistoryURL(station_id string, start, end time.Time) string {
+	v := url.Values{
+		"start": []string{start.UTC().Format(time.RFC3339)},
+		"end":   []string{end.UTC().Format(time.RFC3339)},
+	}
+	return n.formatQueryURL("/stations/%s/observations", station_id, v)
+}
+
+// formatURL returns the URL of a latest observation endpoint, which is the
+// only one supporting the require_qc parameter.
+func (n *NOAAWeatherAPI) formatURL(path string, station_id string) string {
+	v := url.Values{
+		"require_qc": []string{strconv.FormatBool(n.RequireQC)},
+	}
+	return n.formatQueryURL(path, station_id, v)
+}
+
+func (n *NOAAWeatherAPI) formatQueryURL(path string, station_id string, v url.Values) string {
+	return n.resolveURL(fmt.Sprintf(path, url.PathEscape(station_id)), v)
+}
+
+// resolveURL appends the endpoint path to the base URL. Any path of the base
+// URL is kept so the API can be served below a prefix by a gateway.
+func (n *NOAAWeatherAPI) resolveURL(path string, v url.Values) string {
+	u := *n.baseParsedURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = ""
+	u.RawQuery = v.Encode()
+	return u.String()
 }
@@ -1,13 +1,26 @@
 package noaa_weather_api
 
 import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -271,10 +284,246 @@ func TestWeatherGeneratesImperialMultiple(t *testing.T) {
 	}))
 	defer ts.Close()
 
+	expected := testutil.MustMetric(
+		"noaa_weather",
+		map[string]string{
+			"station": "KSUA",
+		},
+		map[string]interface{}{
+			"temperature":  float64(69.8),
+			"humidity":     float64(52.802638324228),
+			"pressure":     float64(101520),
+			"visibility":   float64(10),
+			"dewpoint":     float64(11),
+			"wind_speed":   float64(13.871970167806092),
+			"wind_degrees": float64(340),
+		},
+		time.Unix(1636311000, 0),
+	)
+
 	n := &NOAAWeatherAPI{
 		BaseURL:   ts.URL,
 		StationID: []string{"KSUA", "KSUA"},
 		Units:     "imperial",
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, []string{"KSUA"}, n.StationID)
+
+	var acc testutil.Accumulator
+
+	require.NoError(t, n.Gather(&acc))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, acc.GetTelegrafMetrics())
+
+	n = &NOAAWeatherAPI{
+		BaseURL:                ts.URL,
+		StationID:              []string{"KSUA", "KSUA"},
+		Units:                  "imperial",
+		AllowDuplicateStations: true,
+	}
+	require.NoError(t, n.Init())
+
+	acc = testutil.Accumulator{}
+
+	require.NoError(t, n.Gather(&acc))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected, expected}, acc.GetTelegrafMetrics())
+}
+
+func TestFormatURL(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		Units:   "metric",
+		BaseURL: "http://foo.com",
+	}
+	require.NoError(t, n.Init())
+
+	require.Equal(t,
+		"http://foo.com/stations/KSUA/observations/latest?require_qc=false",
+		n.formatURL("/stations/%s/observations/latest", "KSUA"))
+
+	n.RequireQC = true
+	require.Equal(t,
+		"http://foo.com/stations/KSUA/observations/latest?require_qc=true",
+		n.formatURL("/stations/%s/observations/latest", "KSUA"))
+
+	n = &NOAAWeatherAPI{
+		BaseURL:         "http://foo.com",
+		ObservationPath: "/mirror/%s/latest.json",
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t,
+		"http://foo.com/mirror/KSUA/latest.json?require_qc=false",
+		n.formatURL(n.ObservationPath, "KSUA"))
+
+	start := time.Unix(1636286400, 0)
+	require.Equal(t,
+		"http://foo.com/stations/KSUA/observations?end=2021-11-07T19%3A00%3A00Z&start=2021-11-07T12%3A00%3A00Z",
+		n.formatHistoryURL("KSUA", start, start.Add(7*time.Hour)))
+}
+
+func TestObservationPathUnits(t *testing.T) {
+	for _, units := range []string{"metric", "imperial"} {
+		n := &NOAAWeatherAPI{
+			BaseURL:                  "http://foo.com",
+			Units:                    units,
+			ObservationPath:          "/{units}/stations/%s/latest",
+			SecondaryObservationPath: "/mesonet/{units}/%s",
+		}
+		require.NoError(t, n.Init())
+		require.Equal(t,
+			"http://foo.com/"+units+"/stations/KSUA/latest?require_qc=false",
+			n.formatURL(n.ObservationPath, "KSUA"))
+		require.Equal(t,
+			"http://foo.com/mesonet/"+units+"/KSUA?require_qc=false",
+			n.formatURL(n.SecondaryObservationPath, "KSUA"))
+	}
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         "http://foo.com",
+		ObservationPath: "/{system}/stations/%s/latest",
+	}
+	err := n.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown placeholder {system}")
+}
+
+func TestFormatURLBasePath(t *testing.T) {
+	for _, base := range []string{"http://foo.com/nws", "http://foo.com/nws/"} {
+		n := &NOAAWeatherAPI{
+			BaseURL: base,
+		}
+		require.NoError(t, n.Init())
+
+		require.Equal(t,
+			"http://foo.com/nws/stations/KSUA/observations/latest?require_qc=false",
+			n.formatURL(n.ObservationPath, "KSUA"))
+		require.Equal(t,
+			"http://foo.com/nws/stations/KSUA",
+			n.formatStationURL("KSUA"))
+	}
+}
+
+func TestInvalidObservationPath(t *testing.T) {
+	for _, path := range []string{
+		"/stations/latest",
+		"/stations/%s/%s/latest",
+		"/stations/%d/latest",
+		"/stations/%s/%%/latest",
+	} {
+		n := &NOAAWeatherAPI{
+			ObservationPath: path,
+		}
+		require.Error(t, n.Init(), path)
+	}
+}
+
+func TestDefaultUnits(t *testing.T) {
+	n := &NOAAWeatherAPI{}
+	require.NoError(t, n.Init())
+
+	require.Equal(t, "imperial", n.Units)
+}
+
+func newStationServer(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rsp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprintln(w, rsp)
+		require.NoError(t, err)
+	}))
+}
+
+func TestIncludeRawMessage(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	for _, include := range []bool{false, true} {
+		n := &NOAAWeatherAPI{
+			BaseURL:           ts.URL,
+			StationID:         []string{"KSUA"},
+			IncludeRawMessage: include,
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Len(t, acc.Metrics, 1)
+
+		metar, ok := acc.Metrics[0].Fields["metar"]
+		require.Equal(t, include, ok)
+		if include {
+			require.Equal(t, "KSUA 071850Z 34012G21KT 10SM FEW075 21/11 A2998", metar)
+		}
+	}
+}
+
+const sampleHistoryResponse = `
+{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "id": "https://api.weather.gov/stations/KSUA/observations/2021-11-07T18:50:00+00:00",
+      "type": "Feature",
+      "properties": {
+        "station": "https://api.weather.gov/stations/KSUA",
+        "timestamp": "2021-11-07T18:50:00+00:00",
+        "temperature": {"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "V"},
+        "dewpoint": {"unitCode": "wmoUnit:degC", "value": 11, "qualityControl": "V"},
+        "windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": 340, "qualityControl": "V"},
+        "windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": 22.32, "qualityControl": "V"},
+        "barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101520, "qualityControl": "V"},
+        "visibility": {"unitCode": "wmoUnit:m", "value": 16090, "qualityControl": "C"},
+        "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 52.802638324228, "qualityControl": "V"}
+      }
+    },
+    {
+      "id": "https://api.weather.gov/stations/KSUA/observations/2021-11-07T17:50:00+00:00",
+      "type": "Feature",
+      "properties": {
+        "station": "https://api.weather.gov/stations/KSUA",
+        "timestamp": "2021-11-07T17:50:00+00:00",
+        "temperature": {"unitCode": "wmoUnit:degC", "value": 20, "qualityControl": "V"},
+        "dewpoint": {"unitCode": "wmoUnit:degC", "value": 12, "qualityControl": "V"},
+        "windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": 330, "qualityControl": "V"},
+        "windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": 18, "qualityControl": "V"},
+        "barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101490, "qualityControl": "V"},
+        "visibility": {"unitCode": "wmoUnit:m", "value": 16090, "qualityControl": "C"},
+        "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 60, "qualityControl": "V"}
+      }
+    }
+  ]
+}
+`
+
+func TestWeatherHistory(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/stations/KSUA/observations" {
+			require.Equal(t, "2021-11-07T12:00:00Z", r.URL.Query().Get("start"))
+			require.Equal(t, "2021-11-07T19:00:00Z", r.URL.Query().Get("end"))
+			rsp = sampleHistoryResponse
+			w.Header()["Content-Type"] = []string{"application/geo+json"}
+		} else {
+			require.Fail(t, "Cannot handle request")
+		}
+
+		_, err := fmt.Fprintln(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		StationID:       []string{"KSUA"},
+		Units:           "metric",
+		HistoryStart:    "2021-11-07T12:00:00Z",
+		HistoryDuration: config.Duration(7 * time.Hour),
 	}
 	require.NoError(t, n.Init())
 
@@ -282,6 +531,7 @@ func TestWeatherGeneratesImperialMultiple(t *testing.T) {
 
 	require.NoError(t, n.Gather(&acc))
 
+	// The observations are reported oldest first
 	expected := []telegraf.Metric{
 		testutil.MustMetric(
 			"noaa_weather",
@@ -289,15 +539,15 @@ func TestWeatherGeneratesImperialMultiple(t *testing.T) {
 				"station": "KSUA",
 			},
 			map[string]interface{}{
-				"temperature":  float64(69.8),
-				"humidity":     float64(52.802638324228),
-				"pressure":     float64(101520),
-				"visibility":   float64(10),
-				"dewpoint":     float64(11),
-				"wind_speed":   float64(13.871970167806092),
-				"wind_degrees": float64(340),
+				"temperature":  float64(20),
+				"humidity":     float64(60),
+				"pressure":     float64(101490),
+				"visibility":   float64(16090),
+				"dewpoint":     float64(12),
+				"wind_speed":   float64(18),
+				"wind_degrees": float64(330),
 			},
-			time.Unix(1636311000, 0),
+			time.Unix(1636307400, 0),
 		),
 		testutil.MustMetric(
 			"noaa_weather",
@@ -305,12 +555,12 @@ func TestWeatherGeneratesImperialMultiple(t *testing.T) {
 				"station": "KSUA",
 			},
 			map[string]interface{}{
-				"temperature":  float64(69.8),
+				"temperature":  float64(21),
 				"humidity":     float64(52.802638324228),
 				"pressure":     float64(101520),
-				"visibility":   float64(10),
+				"visibility":   float64(16090),
 				"dewpoint":     float64(11),
-				"wind_speed":   float64(13.871970167806092),
+				"wind_speed":   float64(22.32),
 				"wind_degrees": float64(340),
 			},
 			time.Unix(1636311000, 0),
@@ -319,21 +569,2586 @@ func TestWeatherGeneratesImperialMultiple(t *testing.T) {
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
 }
 
-func TestFormatURL(t *testing.T) {
+func TestSortByTimestamp(t *testing.T) {
+	statuses := []*Status{
+		{Timestamp: "2021-11-07T17:50:00+00:00"},
+		{Timestamp: "2021-11-07T18:50:00+00:00"},
+		{Timestamp: "2021-11-07T16:50:00+00:00"},
+		{Timestamp: "invalid"},
+		{Timestamp: "2021-11-07T18:20:00+00:00"},
+	}
+	sortByTimestamp(statuses)
+
+	timestamps := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		timestamps = append(timestamps, status.Timestamp)
+	}
+	require.Equal(t, []string{
+		"invalid",
+		"2021-11-07T16:50:00+00:00",
+		"2021-11-07T17:50:00+00:00",
+		"2021-11-07T18:20:00+00:00",
+		"2021-11-07T18:50:00+00:00",
+	}, timestamps)
+}
+
+func TestHistoryStartRequiresDuration(t *testing.T) {
 	n := &NOAAWeatherAPI{
-		Units:   "metric",
-		BaseURL: "http://foo.com",
+		HistoryStart: "2021-11-07T12:00:00Z",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestCollectStats(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:      ts.URL,
+		StationID:    []string{"KSUA", "KXXX"},
+		CollectStats: true,
 	}
 	require.NoError(t, n.Init())
 
-	require.Equal(t,
-		"http://foo.com/stations/KSUA/observations/latest?require_qc=false",
-		n.formatURL("/stations/%s/observations/latest", "KSUA"))
+	for i := 0; i < 2; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+
+		for _, station := range []string{"KSUA", "KXXX"} {
+			var found bool
+			for _, m := range acc.Metrics {
+				if m.Measurement != "noaa_weather_internal" || m.Tags["station"] != station {
+					continue
+				}
+				found = true
+				duration := m.Fields["request_duration_ms"].(float64)
+				require.GreaterOrEqual(t, duration, float64(0))
+				require.Less(t, duration, float64(5000))
+				require.Equal(t, int64(i+1), m.Fields["requests_total"])
+				if station == "KSUA" {
+					require.Equal(t, int64(0), m.Fields["errors_total"])
+				} else {
+					require.Equal(t, int64(i+1), m.Fields["errors_total"])
+				}
+			}
+			require.True(t, found)
+		}
+	}
 }
 
-func TestDefaultUnits(t *testing.T) {
-	n := &NOAAWeatherAPI{}
+func TestWeatherNoContent(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleNoContent,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+	}
 	require.NoError(t, n.Init())
 
-	require.Equal(t, "imperial", n.Units)
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+
+	require.Empty(t, acc.GetTelegrafMetrics())
+	require.Len(t, acc.Errors, 1)
+	require.EqualError(t, acc.Errors[0], "station KSUA returned no observation")
+}
+
+func TestWeatherInvalidTimestamp(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": strings.Replace(sampleStatusResponse,
+			`"timestamp": "2021-11-07T18:50:00+00:00"`, `"timestamp": "yesterday"`, 1),
+	})
+	defer ts.Close()
+
+	for _, useNow := range []bool{false, true} {
+		n := &NOAAWeatherAPI{
+			BaseURL:          ts.URL,
+			StationID:        []string{"KSUA"},
+			UseNowOnParseErr: useNow,
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		before := time.Now()
+		require.NoError(t, n.Gather(&acc))
+
+		require.Len(t, acc.Errors, 1)
+		require.Contains(t, acc.Errors[0].Error(), "station KSUA returned invalid timestamp")
+
+		if !useNow {
+			require.Empty(t, acc.GetTelegrafMetrics())
+			continue
+		}
+		metrics := acc.GetTelegrafMetrics()
+		require.Len(t, metrics, 1)
+		require.False(t, metrics[0].Time().Before(before))
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{
+			value:    "2021-11-07T18:50:00+00:00",
+			expected: time.Unix(1636311000, 0),
+		},
+		{
+			value:    "2021-11-07T18:50:00.123+00:00",
+			expected: time.Unix(1636311000, 123000000),
+		},
+		{
+			value:    "2021-11-07T18:50:00Z",
+			expected: time.Unix(1636311000, 0),
+		},
+		{
+			value:    "2021-11-07T18:50:00",
+			expected: time.Unix(1636311000, 0),
+		},
+		{
+			value:    "2021-11-07T18:50:00.5",
+			expected: time.Unix(1636311000, 500000000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			tm, err := parseTimestamp(tt.value)
+			require.NoError(t, err)
+			require.True(t, tt.expected.Equal(tm), "expected %v, got %v", tt.expected, tm)
+		})
+	}
+
+	_, err := parseTimestamp("2021-11-07")
+	require.Error(t, err)
+}
+
+const sampleStationResponse = `
+{
+  "@id": "https://api.weather.gov/stations/KSUA",
+  "@type": "wx:ObservationStation",
+  "geometry": "POINT(-80.22 27.18)",
+  "elevation": {
+    "unitCode": "wmoUnit:m",
+    "value": 4.8768
+  },
+  "stationIdentifier": "KSUA",
+  "name": "Stuart, Witham Field",
+  "timeZone": "America/New_York",
+  "forecast": "https://api.weather.gov/zones/forecast/FLZ164",
+  "county": "https://api.weather.gov/zones/county/FLC085",
+  "fireWeatherZone": "https://api.weather.gov/zones/fire/FLZ164"
+}
+`
+
+func TestIncludeStationMetadata(t *testing.T) {
+	var stationRequests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/stations/KSUA/observations/latest":
+			rsp = sampleStatusResponse
+		case "/stations/KSUA":
+			stationRequests++
+			rsp = sampleStationResponse
+		default:
+			require.Fail(t, "Cannot handle request")
+		}
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprintln(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                ts.URL,
+		StationID:              []string{"KSUA"},
+		IncludeStationMetadata: true,
+	}
+	require.NoError(t, n.Init())
+
+	for i := 0; i < 2; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Empty(t, acc.Errors)
+		require.Len(t, acc.Metrics, 1)
+		require.Equal(t, map[string]string{
+			"station":   "KSUA",
+			"name":      "Stuart, Witham Field",
+			"state":     "FL",
+			"county":    "FLC085",
+			"time_zone": "America/New_York",
+		}, acc.Metrics[0].Tags)
+	}
+	require.Equal(t, 1, stationRequests)
+}
+
+func TestPrefetchMetadata(t *testing.T) {
+	var mu sync.Mutex
+	stationRequests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/stations/KSUA/observations/latest", "/stations/KFPR/observations/latest":
+			rsp = sampleStatusResponse
+		case "/stations/KSUA", "/stations/KFPR":
+			mu.Lock()
+			stationRequests[r.URL.Path]++
+			mu.Unlock()
+			rsp = sampleStationResponse
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprintln(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                ts.URL,
+		StationID:              []string{"KSUA", "KFPR"},
+		IncludeStationMetadata: true,
+		PrefetchMetadata:       true,
+		MaxParallel:            1,
+	}
+	require.NoError(t, n.Init())
+	require.NotNil(t, n.cachedMetadata("KSUA"))
+	require.NotNil(t, n.cachedMetadata("KFPR"))
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+	require.Equal(t, map[string]int{"/stations/KSUA": 1, "/stations/KFPR": 1}, stationRequests)
+
+	// Failures only prevent the startup with strict_prefetch
+	for _, strict := range []bool{false, true} {
+		n = &NOAAWeatherAPI{
+			BaseURL:                ts.URL,
+			StationID:              []string{"KSUA", "KVRB"},
+			IncludeStationMetadata: true,
+			PrefetchMetadata:       true,
+			StrictPrefetch:         strict,
+			Log:                    testutil.Logger{},
+		}
+		err := n.Init()
+		if !strict {
+			require.NoError(t, err)
+			require.NotNil(t, n.cachedMetadata("KSUA"))
+			require.Nil(t, n.cachedMetadata("KVRB"))
+			continue
+		}
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "KVRB")
+	}
+
+	n = &NOAAWeatherAPI{
+		PrefetchMetadata: true,
+	}
+	require.Error(t, n.Init())
+}
+
+func TestStationStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		expected string
+	}{
+		{name: "active", status: "active", expected: "true"},
+		{name: "inactive", status: "inactive", expected: "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station := strings.Replace(sampleStationResponse, `"stationIdentifier"`, `"status": "`+tt.status+`",
+  "stationIdentifier"`, 1)
+			ts := newStationServer(t, map[string]string{
+				"/stations/KSUA/observations/latest": sampleStatusResponse,
+				"/stations/KSUA":                     station,
+			})
+			defer ts.Close()
+
+			n := &NOAAWeatherAPI{
+				BaseURL:                ts.URL,
+				StationID:              []string{"KSUA"},
+				IncludeStationMetadata: true,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+			require.Equal(t, tt.expected, acc.Metrics[0].Tags["active"])
+		})
+	}
+}
+
+func TestStationLabels(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+		"/stations/KFPR/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		labelRequired bool
+		expected      map[string]string
+	}{
+		{
+			name: "fallback to identifier",
+			expected: map[string]string{
+				"KSUA": "Stuart FL Airport",
+				"KFPR": "KFPR",
+			},
+		},
+		{
+			name:          "label required",
+			labelRequired: true,
+			expected: map[string]string{
+				"KSUA": "Stuart FL Airport",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:       ts.URL,
+				StationID:     []string{"KSUA", "KFPR"},
+				StationLabels: map[string]string{"KSUA": "Stuart FL Airport"},
+				LabelRequired: tt.labelRequired,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Metrics, 2)
+
+			actual := make(map[string]string)
+			for _, m := range acc.Metrics {
+				if label, ok := m.Tags["station_name"]; ok {
+					actual[m.Tags["station"]] = label
+				}
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestValidateStations(t *testing.T) {
+	tests := []struct {
+		name     string
+		stations []string
+		err      string
+	}{
+		{
+			name:     "valid",
+			stations: []string{"KSUA", "ksua", "C4346", "AU477"},
+		},
+		{
+			name:     "invalid",
+			stations: []string{"KSUA", "", "K SUA", "KSUA/1"},
+			err:      `invalid station_id: "", "K SUA", "KSUA/1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				StationID: tt.stations,
+			}
+			err := n.Init()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  config.Duration
+		expected time.Duration
+		err      bool
+	}{
+		{
+			name:     "sub-second",
+			timeout:  config.Duration(500 * time.Millisecond),
+			expected: 500 * time.Millisecond,
+		},
+		{
+			name:     "unset",
+			expected: defaultResponseTimeout,
+		},
+		{
+			name:    "negative",
+			timeout: config.Duration(-time.Second),
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				ResponseTimeout: tt.timeout,
+			}
+			err := n.Init()
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, n.client.Timeout)
+		})
+	}
+}
+
+func TestClampHumidity(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": strings.Replace(sampleStatusResponse,
+			`"value": 52.802638324228`, `"value": 100.0000003`, 1),
+	})
+	defer ts.Close()
+
+	for _, clamp := range []bool{false, true} {
+		n := &NOAAWeatherAPI{
+			BaseURL:       ts.URL,
+			StationID:     []string{"KSUA"},
+			ClampHumidity: clamp,
+			CollectStats:  true,
+			Log:           testutil.Logger{},
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+
+		humidity, ok := acc.FloatField("noaa_weather", "humidity")
+		require.True(t, ok)
+		internal, ok := acc.Get("noaa_weather_internal")
+		require.True(t, ok)
+		if clamp {
+			require.Equal(t, float64(100), humidity)
+			require.Equal(t, int64(1), internal.Fields["humidity_clamped_total"])
+		} else {
+			require.Equal(t, 100.0000003, humidity)
+			require.NotContains(t, internal.Fields, "humidity_clamped_total")
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+	}
+	require.NoError(t, n.Init())
+	require.NoError(t, n.Validate())
+
+	n = &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA", "KXXX"},
+	}
+	require.NoError(t, n.Init())
+	err := n.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unreachable stations: KXXX")
+	require.Contains(t, err.Error(), "404 Not Found")
+	require.NotContains(t, err.Error(), "KSUA (")
+}
+
+func TestValidateProbeMethod(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	allowHead := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		allow := allowHead
+		mu.Unlock()
+
+		if r.Method == http.MethodHead && !allow {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		if r.Method == http.MethodGet {
+			_, err := fmt.Fprint(w, sampleStatusResponse)
+			require.NoError(t, err)
+		}
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:     ts.URL,
+		StationID:   []string{"KSUA"},
+		ProbeMethod: "HEAD",
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.NoError(t, n.Validate())
+	require.Equal(t, []string{"HEAD"}, methods)
+
+	// Servers not allowing HEAD are probed with GET
+	mu.Lock()
+	allowHead = false
+	methods = nil
+	mu.Unlock()
+	require.NoError(t, n.Validate())
+	require.Equal(t, []string{"HEAD", "GET"}, methods)
+
+	n = &NOAAWeatherAPI{ProbeMethod: "POST"}
+	require.Error(t, n.Init())
+}
+
+func TestWindCardinal(t *testing.T) {
+	tests := []struct {
+		degrees  float64
+		expected string
+	}{
+		{degrees: 0, expected: "N"},
+		{degrees: 11.24, expected: "N"},
+		{degrees: 11.25, expected: "NNE"},
+		{degrees: 90, expected: "E"},
+		{degrees: 135, expected: "SE"},
+		{degrees: 340, expected: "NNW"},
+		{degrees: 348.74, expected: "NNW"},
+		{degrees: 348.75, expected: "N"},
+		{degrees: 360, expected: "N"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.degrees), func(t *testing.T) {
+			require.Equal(t, tt.expected, windCardinal(tt.degrees))
+		})
+	}
+}
+
+func TestIncludeWindCardinal(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+		"/stations/KFPR/observations/latest": strings.Replace(sampleStatusResponse,
+			`"value": 340,`, `"value": null,`, 1),
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA", "KFPR"},
+		IncludeWindCardinal: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 2)
+
+	for _, m := range acc.Metrics {
+		if m.Tags["station"] == "KSUA" {
+			require.Equal(t, "NNW", m.Fields["wind_cardinal"])
+			require.Equal(t, float64(340), m.Fields["wind_degrees"])
+		} else {
+			require.NotContains(t, m.Fields, "wind_cardinal")
+			require.NotContains(t, m.Fields, "wind_degrees")
+		}
+	}
+}
+
+func TestWindComponents(t *testing.T) {
+	tests := []struct {
+		degrees float64
+		u       float64
+		v       float64
+	}{
+		{degrees: 0, u: 0, v: -10},
+		{degrees: 90, u: -10, v: 0},
+		{degrees: 180, u: 0, v: 10},
+		{degrees: 270, u: 10, v: 0},
+		{degrees: 225, u: 7.0711, v: 7.0711},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.degrees), func(t *testing.T) {
+			u, v := windComponents(10, tt.degrees)
+			require.InDelta(t, tt.u, u, 1e-4)
+			require.InDelta(t, tt.v, v, 1e-4)
+		})
+	}
+}
+
+func TestIncludeWindComponents(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+		"/stations/KFPR/observations/latest": strings.Replace(sampleStatusResponse,
+			`"value": 340,`, `"value": null,`, 1),
+	})
+	defer ts.Close()
+
+	for _, only := range []bool{false, true} {
+		t.Run(fmt.Sprintf("only=%v", only), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:            ts.URL,
+				StationID:          []string{"KSUA", "KFPR"},
+				Units:              "metric",
+				WindComponents:     true,
+				WindComponentsOnly: only,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Metrics, 2)
+
+			for _, m := range acc.Metrics {
+				if m.Tags["station"] != "KSUA" {
+					require.NotContains(t, m.Fields, "wind_u")
+					require.NotContains(t, m.Fields, "wind_v")
+					require.Contains(t, m.Fields, "wind_speed")
+					continue
+				}
+				speed := 22.32
+				require.InDelta(t, -speed*math.Sin(340*math.Pi/180), m.Fields["wind_u"], 1e-9)
+				require.InDelta(t, -speed*math.Cos(340*math.Pi/180), m.Fields["wind_v"], 1e-9)
+				if only {
+					require.NotContains(t, m.Fields, "wind_speed")
+					require.NotContains(t, m.Fields, "wind_degrees")
+				} else {
+					require.InDelta(t, speed, m.Fields["wind_speed"], 1e-9)
+					require.Equal(t, float64(340), m.Fields["wind_degrees"])
+				}
+			}
+		})
+	}
+
+	n := &NOAAWeatherAPI{WindComponentsOnly: true}
+	require.Error(t, n.Init())
+}
+
+func TestCompareStations(t *testing.T) {
+	warmer := strings.Replace(sampleStatusResponse, `"value": 21,`, `"value": 23.5,`, 1)
+	require.NotEqual(t, sampleStatusResponse, warmer)
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": warmer,
+		"/stations/KFPR/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		StationID:       []string{"KSUA", "KFPR"},
+		Units:           "metric",
+		CompareStations: [][]string{{"KSUA", "KFPR"}, {"KSUA", "KGONE"}},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	var deltas []telegraf.Metric
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Name() == "noaa_weather_delta" {
+			deltas = append(deltas, m)
+		}
+	}
+	require.Len(t, deltas, 1)
+	require.Equal(t, map[string]string{"station_a": "KSUA", "station_b": "KFPR"}, deltas[0].Tags())
+	require.InDelta(t, 2.5, deltas[0].Fields()["temperature_delta"], 1e-9)
+	require.Equal(t, 0.0, deltas[0].Fields()["humidity_delta"])
+	require.NotContains(t, deltas[0].Fields(), "metar_delta")
+
+	n = &NOAAWeatherAPI{CompareStations: [][]string{{"KSUA"}}}
+	require.Error(t, n.Init())
+}
+
+func TestDeltaOnly(t *testing.T) {
+	var rsp atomic.Value
+	rsp.Store(sampleStatusResponse)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, rsp.Load())
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		Units:     "metric",
+		DeltaOnly: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 1)
+	require.Contains(t, acc.Metrics[0].Fields, "temperature")
+	require.Contains(t, acc.Metrics[0].Fields, "humidity")
+
+	// Only the changed humidity is reported
+	rsp.Store(strings.Replace(sampleStatusResponse, `"value": 52.802638324228,`, `"value": 60.1,`, 1))
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, map[string]interface{}{"humidity": 60.1}, acc.Metrics[0].Fields)
+
+	// Nothing is reported without changes
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Metrics)
+}
+
+func TestSkipDuplicateObservations(t *testing.T) {
+	var rsp atomic.Value
+	rsp.Store(sampleStatusResponse)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, rsp.Load())
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                   ts.URL,
+		StationID:                 []string{"KSUA"},
+		SkipDuplicateObservations: true,
+		Log:                       testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 1)
+
+	// The station did not update, nothing is reported
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Empty(t, acc.Metrics)
+
+	rsp.Store(strings.Replace(sampleStatusResponse, `"timestamp": "2021-11-07T18:50:00+00:00"`, `"timestamp": "2021-11-07T19:50:00+00:00"`, 1))
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, time.Date(2021, 11, 7, 19, 50, 0, 0, time.UTC), acc.Metrics[0].Time.UTC())
+}
+
+func TestSkipDuplicateHistoryObservations(t *testing.T) {
+	var rsp atomic.Value
+	rsp.Store(sampleHistoryResponse)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/geo+json"}
+		_, err := fmt.Fprint(w, rsp.Load())
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	n := &NOAAWeatherAPI{
+		BaseURL:                   ts.URL,
+		StationID:                 []string{"KSUA"},
+		HistoryDuration:           config.Duration(2 * time.Hour),
+		SkipDuplicateObservations: true,
+		Log:                       testutil.Logger{},
+	}
+	n.setNow(func() time.Time { return now })
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+
+	// The next window overlaps the previous one, only the new observation
+	// is reported
+	feature := `{
+      "type": "Feature",
+      "properties": {
+        "station": "https://api.weather.gov/stations/KSUA",
+        "timestamp": "2021-11-07T19:50:00+00:00",
+        "temperature": {"unitCode": "wmoUnit:degC", "value": 22, "qualityControl": "V"}
+      }
+    },`
+	rsp.Store(strings.Replace(sampleHistoryResponse, `"features": [`, `"features": [`+feature, 1))
+	now = now.Add(time.Hour)
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, time.Date(2021, 11, 7, 19, 50, 0, 0, time.UTC), acc.Metrics[0].Time.UTC())
+}
+
+func TestRoundDecimals(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		units    string
+		decimals int
+		expected map[string]interface{}
+	}{
+		{
+			units:    "imperial",
+			decimals: 2,
+			expected: map[string]interface{}{
+				"temperature":  float64(69.8),
+				"humidity":     float64(52.8),
+				"pressure":     float64(101520),
+				"visibility":   float64(10),
+				"dewpoint":     float64(11),
+				"wind_speed":   float64(13.87),
+				"wind_degrees": float64(340),
+			},
+		},
+		{
+			units:    "metric",
+			decimals: 0,
+			expected: map[string]interface{}{
+				"temperature":  float64(21),
+				"humidity":     float64(53),
+				"pressure":     float64(101520),
+				"visibility":   float64(16090),
+				"dewpoint":     float64(11),
+				"wind_speed":   float64(22),
+				"wind_degrees": float64(340),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			decimals := tt.decimals
+			n := &NOAAWeatherAPI{
+				BaseURL:       ts.URL,
+				StationID:     []string{"KSUA"},
+				Units:         tt.units,
+				RoundDecimals: &decimals,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			m, ok := acc.Get("noaa_weather")
+			require.True(t, ok)
+			require.Equal(t, tt.expected, m.Fields)
+		})
+	}
+
+	decimals := -1
+	n := &NOAAWeatherAPI{RoundDecimals: &decimals}
+	require.Error(t, n.Init())
+}
+
+func TestIncludeQCRequired(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	for _, requireQC := range []bool{false, true} {
+		t.Run(fmt.Sprintf("require_qc=%v", requireQC), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:           ts.URL,
+				StationID:         []string{"KSUA"},
+				RequireQC:         requireQC,
+				IncludeQCRequired: true,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+			require.Equal(t, requireQC, acc.Metrics[0].Fields["qc_required"])
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		RequireQC: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 1)
+	require.NotContains(t, acc.Metrics[0].Fields, "qc_required")
+}
+
+func TestFieldPrefix(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA"},
+		Units:               "metric",
+		FieldPrefix:         "nws_",
+		IncludeWindCardinal: true,
+		CollectStats:        true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+
+	m, ok := acc.Get("noaa_weather")
+	require.True(t, ok)
+	require.Equal(t, map[string]interface{}{
+		"nws_temperature":   float64(21),
+		"nws_humidity":      float64(52.802638324228),
+		"nws_pressure":      float64(101520),
+		"nws_visibility":    float64(16090),
+		"nws_dewpoint":      float64(11),
+		"nws_wind_speed":    float64(22.32),
+		"nws_wind_degrees":  float64(340),
+		"nws_wind_cardinal": "NNW",
+	}, m.Fields)
+
+	internal, ok := acc.Get("noaa_weather_internal")
+	require.True(t, ok)
+	require.Contains(t, internal.Fields, "requests_total")
+}
+
+func TestUnitsNone(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		Units:     "none",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"noaa_weather",
+			map[string]string{
+				"station": "KSUA",
+			},
+			map[string]interface{}{
+				"temperature":       float64(21),
+				"temperature_unit":  "wmoUnit:degC",
+				"humidity":          float64(52.802638324228),
+				"humidity_unit":     "wmoUnit:percent",
+				"pressure":          float64(101520),
+				"pressure_unit":     "wmoUnit:Pa",
+				"visibility":        float64(16090),
+				"visibility_unit":   "wmoUnit:m",
+				"dewpoint":          float64(11),
+				"dewpoint_unit":     "wmoUnit:degC",
+				"wind_speed":        float64(22.32),
+				"wind_speed_unit":   "wmoUnit:km_h-1",
+				"wind_degrees":      float64(340),
+				"wind_degrees_unit": "wmoUnit:degree_(angle)",
+			},
+			time.Unix(1636311000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestPressureTendency(t *testing.T) {
+	pressure := "101520"
+	timestamp := "2021-11-07T18:50:00+00:00"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		rsp := strings.Replace(sampleStatusResponse, `"value": 101520`, `"value": `+pressure, 1)
+		rsp = strings.Replace(rsp, `"timestamp": "2021-11-07T18:50:00+00:00"`, `"timestamp": "`+timestamp+`"`, 1)
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                 ts.URL,
+		StationID:               []string{"KSUA"},
+		IncludePressureTendency: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.False(t, acc.HasField("noaa_weather", "pressure_tendency"))
+	require.False(t, acc.HasTag("noaa_weather", "pressure_trend"))
+
+	pressure = "101370"
+	timestamp = "2021-11-07T19:50:00+00:00"
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	tendency, ok := acc.FloatField("noaa_weather", "pressure_tendency")
+	require.True(t, ok)
+	require.InDelta(t, -1.5, tendency, 1e-9)
+	require.Equal(t, "falling", acc.TagValue("noaa_weather", "pressure_trend"))
+
+	// The same observation again is not compared against itself
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.False(t, acc.HasField("noaa_weather", "pressure_tendency"))
+	require.False(t, acc.HasTag("noaa_weather", "pressure_trend"))
+
+	timestamp = "2021-11-07T20:50:00+00:00"
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	tendency, ok = acc.FloatField("noaa_weather", "pressure_tendency")
+	require.True(t, ok)
+	require.Equal(t, float64(0), tendency)
+	require.Equal(t, "steady", acc.TagValue("noaa_weather", "pressure_trend"))
+}
+
+func TestSkipWarmupFields(t *testing.T) {
+	// Every gather returns a newer observation
+	var rsp atomic.Value
+	rsp.Store(sampleStatusResponse)
+	observe := func(timestamp string) {
+		rsp.Store(strings.Replace(sampleStatusResponse, `"timestamp": "2021-11-07T18:50:00+00:00"`, `"timestamp": "`+timestamp+`"`, 1))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, rsp.Load())
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:                 ts.URL,
+		StationID:               []string{"KSUA"},
+		IncludePressureTendency: true,
+		SkipWarmupFields:        true,
+		Log:                     testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.True(t, acc.HasField("noaa_weather", "pressure"))
+	require.False(t, acc.HasField("noaa_weather", "pressure_tendency"))
+	require.False(t, acc.HasTag("noaa_weather", "pressure_trend"))
+
+	observe("2021-11-07T19:50:00+00:00")
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	tendency, ok := acc.FloatField("noaa_weather", "pressure_tendency")
+	require.True(t, ok)
+	require.Equal(t, float64(0), tendency)
+
+	// Stations added later warm up on their own
+	n.StationID = append(n.StationID, "KFPR")
+	observe("2021-11-07T20:50:00+00:00")
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 2)
+	for _, m := range acc.Metrics {
+		_, ok := m.Fields["pressure_tendency"]
+		require.Equal(t, m.Tags["station"] == "KSUA", ok)
+	}
+}
+
+func TestUnknownUnitCode(t *testing.T) {
+	response := strings.Replace(sampleStatusResponse, `"unitCode": "wmoUnit:degC",
+    "value": 21,`, `"unitCode": "wmoUnit:degF",
+    "value": 70,`, 1)
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": response,
+		"/stations/KFPR/observations/latest": response,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA", "KFPR"},
+		Units:     "imperial",
+	}
+	require.NoError(t, n.Init())
+
+	for i := 0; i < 2; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+
+		require.Len(t, acc.Metrics, 2)
+		for _, m := range acc.Metrics {
+			require.Equal(t, float64(70), m.Fields["temperature"])
+		}
+		require.Len(t, acc.Errors, 1)
+		require.Contains(t, acc.Errors[0].Error(), `unknown unit code "wmoUnit:degF" of field temperature`)
+	}
+}
+
+func TestMaxParallel(t *testing.T) {
+	var inFlight, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if current <= max || atomic.CompareAndSwapInt32(&peak, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	stations := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		stations = append(stations, fmt.Sprintf("K%03d", i))
+	}
+
+	n := &NOAAWeatherAPI{
+		BaseURL:     ts.URL,
+		StationID:   stations,
+		MaxParallel: 5,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+
+	require.Len(t, acc.Metrics, 50)
+	require.LessOrEqual(t, atomic.LoadInt32(&peak), int32(5))
+	require.Greater(t, atomic.LoadInt32(&peak), int32(1))
+}
+
+func TestMaxObservationAge(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	// The sample observation is two hours old
+	now := time.Date(2021, 11, 7, 20, 50, 0, 0, time.UTC)
+
+	tests := []struct {
+		maxAge   time.Duration
+		expected int
+	}{
+		{maxAge: 0, expected: 1},
+		{maxAge: 3 * time.Hour, expected: 1},
+		{maxAge: 2 * time.Hour, expected: 1},
+		{maxAge: time.Hour, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.maxAge.String(), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:           ts.URL,
+				StationID:         []string{"KSUA"},
+				MaxObservationAge: config.Duration(tt.maxAge),
+				Log:               testutil.Logger{},
+			}
+			n.setNow(func() time.Time { return now })
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, tt.expected)
+		})
+	}
+}
+
+func TestSampleConfig(t *testing.T) {
+	// Enable every option documented in the sample configuration so that
+	// options without a matching struct field are reported as unused.
+	commented := regexp.MustCompile(`(?m)^(\s*)#\s+([A-Za-z\[])`)
+	sample := commented.ReplaceAllString(sampleConfig, "$1$2")
+
+	// The TLS files are read on load, point them to the test fixtures
+	pki := testutil.NewPKI("../../../testutil/pki")
+	caDir := t.TempDir()
+	ca, err := os.ReadFile(pki.CACertPath())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(caDir, "ca.pem"), ca, 0600))
+	sample = strings.NewReplacer(
+		"/etc/telegraf/ca.pem", filepath.ToSlash(pki.CACertPath()),
+		"/etc/telegraf/ca.d", filepath.ToSlash(caDir),
+		"/etc/telegraf/cert.pem", filepath.ToSlash(pki.ClientCertPath()),
+		"/etc/telegraf/key.pem", filepath.ToSlash(pki.ClientKeyPath()),
+	).Replace(sample)
+
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte("[[inputs.noaa_weather_api]]\n"+sample)))
+	require.Len(t, c.Inputs, 1)
+
+	n, ok := c.Inputs[0].Input.(*NOAAWeatherAPI)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"KSUA": "Stuart FL Airport"}, n.StationLabels)
+}
+
+func TestVisibilityUnit(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		units          string
+		visibilityUnit string
+		expected       float64
+	}{
+		{units: "metric", expected: 16090},
+		{units: "imperial", expected: 10},
+		{units: "imperial", visibilityUnit: "km", expected: 16.09},
+		{units: "metric", visibilityUnit: "km", expected: 16.09},
+		{units: "metric", visibilityUnit: "mi", expected: 10},
+		{units: "imperial", visibilityUnit: "m", expected: 16090},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units+"/"+tt.visibilityUnit, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:        ts.URL,
+				StationID:      []string{"KSUA"},
+				Units:          tt.units,
+				VisibilityUnit: tt.visibilityUnit,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+
+			visibility, ok := acc.FloatField("noaa_weather", "visibility")
+			require.True(t, ok)
+			require.InDelta(t, tt.expected, visibility, 1e-9)
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		VisibilityUnit: "ft",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestAcceptedContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		history     bool
+		contentType string
+		accept      string
+		err         bool
+	}{
+		{
+			name:        "latest ld+json",
+			contentType: "application/ld+json",
+			accept:      "application/ld+json",
+		},
+		{
+			name:        "latest json",
+			contentType: "application/json; charset=utf-8",
+			accept:      "application/ld+json",
+		},
+		{
+			name:        "latest html",
+			contentType: "text/html",
+			accept:      "application/ld+json",
+			err:         true,
+		},
+		{
+			name:        "history geo+json",
+			history:     true,
+			contentType: "application/geo+json",
+			accept:      "application/geo+json",
+		},
+		{
+			name:        "history json",
+			history:     true,
+			contentType: "application/json",
+			accept:      "application/geo+json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, tt.accept, r.Header.Get("Accept"))
+				w.Header()["Content-Type"] = []string{tt.contentType}
+				rsp := sampleStatusResponse
+				if tt.history {
+					rsp = sampleHistoryResponse
+				}
+				_, err := fmt.Fprint(w, rsp)
+				require.NoError(t, err)
+			}))
+			defer ts.Close()
+
+			n := &NOAAWeatherAPI{
+				BaseURL:   ts.URL,
+				StationID: []string{"KSUA"},
+			}
+			if tt.history {
+				n.HistoryDuration = config.Duration(time.Hour)
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+
+			if tt.err {
+				require.Len(t, acc.Errors, 1)
+				require.Contains(t, acc.Errors[0].Error(),
+					"returned unexpected content type text/html, expected one of application/ld+json, application/json")
+				require.Empty(t, acc.Metrics)
+				return
+			}
+			require.Empty(t, acc.Errors)
+			require.NotEmpty(t, acc.Metrics)
+		})
+	}
+}
+
+func TestAcceptHeader(t *testing.T) {
+	const accept = "application/vnd.mirror+json; version=2"
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, accept, r.Header.Get("Accept"))
+		w.Header()["Content-Type"] = []string{contentType}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA"},
+		AcceptHeader:        accept,
+		ExpectedContentType: "application/vnd.mirror+json",
+	}
+	require.NoError(t, n.Init())
+
+	contentType = "application/vnd.mirror+json; version=2"
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+
+	// The default content types are not accepted anymore
+	contentType = "application/ld+json"
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(),
+		"returned unexpected content type application/ld+json, expected one of application/vnd.mirror+json")
+	require.Empty(t, acc.Metrics)
+
+	n = &NOAAWeatherAPI{ExpectedContentType: "application/"}
+	require.Error(t, n.Init())
+}
+
+func TestFieldsIncludeExclude(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected map[string]interface{}
+	}{
+		{
+			name:    "include",
+			include: []string{"temperature", "humidity", "metar"},
+			expected: map[string]interface{}{
+				"temperature": float64(21),
+				"humidity":    float64(52.802638324228),
+			},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"visibility", "dewpoint", "wind_speed", "wind_degrees"},
+			expected: map[string]interface{}{
+				"temperature": float64(21),
+				"humidity":    float64(52.802638324228),
+				"pressure":    float64(101520),
+			},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"temperature", "humidity"},
+			exclude: []string{"humidity"},
+			expected: map[string]interface{}{
+				"temperature": float64(21),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:       ts.URL,
+				StationID:     []string{"KSUA"},
+				Units:         "metric",
+				FieldsInclude: tt.include,
+				FieldsExclude: tt.exclude,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+
+			m, ok := acc.Get("noaa_weather")
+			require.True(t, ok)
+			require.Equal(t, tt.expected, m.Fields)
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		FieldsExclude: []string{"temp"},
+	}
+	require.Error(t, n.Init())
+}
+
+func TestAuthorization(t *testing.T) {
+	var header atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header.Store(r.Header.Get("Authorization"))
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		fmt.Fprint(w, sampleStatusResponse)
+	}))
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret-token\n"), 0600))
+
+	tests := []struct {
+		name     string
+		plugin   *NOAAWeatherAPI
+		expected string
+	}{
+		{
+			name:     "none",
+			plugin:   &NOAAWeatherAPI{},
+			expected: "",
+		},
+		{
+			name:     "basic auth",
+			plugin:   &NOAAWeatherAPI{Username: "user", Password: "pa$$word"},
+			expected: "Basic dXNlcjpwYSQkd29yZA==",
+		},
+		{
+			name:     "bearer token",
+			plugin:   &NOAAWeatherAPI{BearerToken: tokenFile},
+			expected: "Bearer secret-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.plugin
+			n.BaseURL = ts.URL
+			n.StationID = []string{"KSUA"}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Equal(t, tt.expected, header.Load())
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		Username:    "user",
+		BearerToken: tokenFile,
+	}
+	require.Error(t, n.Init())
+}
+
+const sampleSparseResponse = `
+{
+  "station": "https://api.weather.gov/stations/KSUA",
+  "timestamp": "2021-11-07T18:50:00+00:00",
+  "temperature": {"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "V"},
+  "dewpoint": {"unitCode": "wmoUnit:degC", "value": null, "qualityControl": "Z"},
+  "windDirection": {"unitCode": "wmoUnit:degree_(angle)", "value": null, "qualityControl": "Z"},
+  "windSpeed": {"unitCode": "wmoUnit:km_h-1", "value": null, "qualityControl": "Z"},
+  "barometricPressure": {"unitCode": "wmoUnit:Pa", "value": 101520, "qualityControl": "V"},
+  "visibility": {"unitCode": "wmoUnit:m", "value": null, "qualityControl": "Z"},
+  "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": null, "qualityControl": "Z"}
+}
+`
+
+func TestCollectCompleteness(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+		"/stations/KSPA/observations/latest": sampleSparseResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA", "KSPA"},
+		Units:               "metric",
+		CollectCompleteness: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	ratios := make(map[string]float64)
+	missing := make(map[string]interface{})
+	for _, m := range acc.GetTelegrafMetrics() {
+		station, _ := m.GetTag("station")
+		ratio, ok := m.GetField("completeness_ratio")
+		require.True(t, ok)
+		ratios[station] = ratio.(float64)
+		missing[station], _ = m.GetField("missing_fields")
+	}
+	require.Equal(t, 1.0, ratios["KSUA"])
+	require.EqualValues(t, 0, missing["KSUA"])
+	require.InDelta(t, 2.0/7.0, ratios["KSPA"], 1e-9)
+	require.EqualValues(t, 5, missing["KSPA"])
+}
+
+func TestStationFromResponse(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		Units: "metric",
+	}
+	require.NoError(t, n.Init())
+
+	var status Status
+	require.NoError(t, json.Unmarshal([]byte(sampleStatusResponse), &status))
+
+	var acc testutil.Accumulator
+	n.GatherWeather(&acc, &status)
+	require.Empty(t, acc.Errors)
+	require.True(t, acc.HasTag("noaa_weather", "station"))
+	require.Equal(t, "KSUA", acc.TagValue("noaa_weather", "station"))
+}
+
+func TestServerSideUnits(t *testing.T) {
+	var accept atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept.Store(r.Header.Get("Accept"))
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		fmt.Fprint(w, sampleStatusResponse)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		StationID:       []string{"KSUA"},
+		Units:           "imperial",
+		ServerSideUnits: "us",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Equal(t, "application/ld+json; units=us", accept.Load())
+
+	// The values are reported as returned by the server
+	temperature, ok := acc.FloatField("noaa_weather", "temperature")
+	require.True(t, ok)
+	require.Equal(t, float64(21), temperature)
+	visibility, ok := acc.FloatField("noaa_weather", "visibility")
+	require.True(t, ok)
+	require.Equal(t, float64(16090), visibility)
+
+	n = &NOAAWeatherAPI{
+		ServerSideUnits: "metric",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestIncludeUnitsTag(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		units           string
+		serverSideUnits string
+		expected        string
+	}{
+		{expected: "imperial"},
+		{units: "metric", expected: "metric"},
+		{units: "imperial", expected: "imperial"},
+		{units: "none", expected: "si"},
+		{serverSideUnits: "si", expected: "si"},
+		{units: "metric", serverSideUnits: "us", expected: "imperial"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units+"/"+tt.serverSideUnits, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:         ts.URL,
+				StationID:       []string{"KSUA"},
+				Units:           tt.units,
+				ServerSideUnits: tt.serverSideUnits,
+				IncludeUnitsTag: true,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Equal(t, tt.expected, acc.TagValue("noaa_weather", "unit_system"))
+		})
+	}
+}
+
+func TestIncludeHourTag(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:        ts.URL,
+		StationID:      []string{"KSUA"},
+		IncludeHourTag: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Equal(t, "18", acc.TagValue("noaa_weather", "obs_hour"))
+}
+
+func TestProblemDetails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stations/KSUA/observations/latest" {
+			w.Header()["Content-Type"] = []string{"application/problem+json"}
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{
+  "correlationId": "1a2b3c",
+  "title": "Invalid Parameter",
+  "type": "https://api.weather.gov/problems/InvalidParameter",
+  "status": 400,
+  "detail": "Parameter \"require_qc\" is invalid: must be a boolean",
+  "instance": "https://api.weather.gov/requests/1a2b3c"
+}`)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"text/plain"}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Bad Request")
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA", "KFPR"},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 2)
+	var errs []string
+	for _, err := range acc.Errors {
+		errs = append(errs, err.Error())
+	}
+	require.ElementsMatch(t, []string{
+		"station KSUA: " + ts.URL + `/stations/KSUA/observations/latest?require_qc=false returned HTTP status 400 Bad Request: Invalid Parameter: Parameter "require_qc" is invalid: must be a boolean`,
+		"station KFPR: " + ts.URL + "/stations/KFPR/observations/latest?require_qc=false returned HTTP status 400 Bad Request",
+	}, errs)
+}
+
+func TestRedirectToErrorPage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.Header()["Content-Type"] = []string{"text/html"}
+			fmt.Fprint(w, "<html><body>Service Unavailable</body></html>")
+			return
+		}
+		http.Redirect(w, r, "/error", http.StatusMovedPermanently)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), ts.URL+"/error returned unexpected content type text/html")
+	require.Contains(t, acc.Errors[0].Error(), "Service Unavailable")
+	require.Empty(t, acc.Metrics)
+}
+
+func TestMetricLayoutNarrow(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleSparseResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:      ts.URL,
+		StationID:    []string{"KSUA"},
+		Units:        "metric",
+		MetricLayout: "narrow",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"noaa_weather_temperature",
+			map[string]string{"station": "KSUA"},
+			map[string]interface{}{"value": float64(21)},
+			time.Unix(1636311000, 0),
+		),
+		testutil.MustMetric(
+			"noaa_weather_pressure",
+			map[string]string{"station": "KSUA"},
+			map[string]interface{}{"value": float64(101520)},
+			time.Unix(1636311000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+
+	n = &NOAAWeatherAPI{
+		MetricLayout: "tall",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestMeasurementNames(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/stations/KSUA/observations/latest":
+			rsp = sampleStatusResponse
+			w.Header()["Content-Type"] = []string{"application/ld+json"}
+		case "/gridpoints/MFL/110,50/forecast":
+			rsp = sampleForecastResponse
+			w.Header()["Content-Type"] = []string{"application/geo+json"}
+		case "/alerts/active/count":
+			rsp = sampleAlertCountResponse
+			w.Header()["Content-Type"] = []string{"application/geo+json"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		plugin   *NOAAWeatherAPI
+		expected []string
+	}{
+		{
+			name:     "defaults",
+			plugin:   &NOAAWeatherAPI{},
+			expected: []string{"noaa_weather", "noaa_weather_alert_counts", "noaa_weather_forecast", "noaa_weather_forecast"},
+		},
+		{
+			name: "configured",
+			plugin: &NOAAWeatherAPI{
+				ObservationMeasurement: "weather",
+				ForecastMeasurement:    "weather_forecast",
+				AlertMeasurement:       "weather_alert",
+			},
+			expected: []string{"weather", "weather_alert", "weather_forecast", "weather_forecast"},
+		},
+		{
+			name: "narrow",
+			plugin: &NOAAWeatherAPI{
+				ObservationMeasurement: "weather",
+				MetricLayout:           "narrow",
+				FieldsInclude:          []string{"temperature"},
+			},
+			expected: []string{"noaa_weather_alert_counts", "noaa_weather_forecast", "noaa_weather_forecast", "weather_temperature"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.plugin
+			n.BaseURL = ts.URL
+			n.StationID = []string{"KSUA"}
+			n.ForecastGridpoints = []string{"MFL/110,50"}
+			n.CollectAlertCounts = true
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			names := make([]string, 0, len(acc.Metrics))
+			for _, m := range acc.Metrics {
+				names = append(names, m.Measurement)
+			}
+			sort.Strings(names)
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	unknown := strings.Replace(sampleSparseResponse, `"station":`, `"unexpectedField": 1, "station":`, 1)
+	nested := strings.Replace(sampleSparseResponse, `"unitCode": "wmoUnit:degC",`, `"unitCode": "wmoUnit:degC", "nestedField": 1,`, 1)
+	require.NotEqual(t, sampleSparseResponse, nested)
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleSparseResponse,
+		"/stations/KUNK/observations/latest": unknown,
+		"/stations/KNST/observations/latest": nested,
+	})
+	defer ts.Close()
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:        ts.URL,
+				StationID:      []string{"KSUA", "KUNK", "KNST"},
+				StrictDecoding: strict,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			if !strict {
+				require.Empty(t, acc.Errors)
+				require.Len(t, acc.Metrics, 3)
+				return
+			}
+			require.Len(t, acc.Errors, 2)
+			var errs []string
+			for _, err := range acc.Errors {
+				errs = append(errs, err.Error())
+			}
+			sort.Strings(errs)
+			require.Contains(t, errs[0], "KNST")
+			require.Contains(t, errs[0], `unknown field "nestedField"`)
+			require.Contains(t, errs[1], "KUNK")
+			require.Contains(t, errs[1], `unknown field "unexpectedField"`)
+			require.Len(t, acc.Metrics, 1)
+			require.Equal(t, "KSUA", acc.Metrics[0].Tags["station"])
+		})
+	}
+}
+
+func TestGeoJSONFeature(t *testing.T) {
+	feature := `{"id": "https://api.weather.gov/stations/KSUA/observations/2021-11-07T12:53:00+00:00", ` +
+		`"type": "Feature", "geometry": {"type": "Point", "coordinates": [-80.22, 27.18]}, ` +
+		`"properties": ` + sampleStatusResponse + `}`
+
+	var expected []telegraf.Metric
+	for _, rsp := range []string{sampleStatusResponse, feature} {
+		ts := newStationServer(t, map[string]string{
+			"/stations/KSUA/observations/latest": rsp,
+		})
+
+		n := &NOAAWeatherAPI{
+			BaseURL:   ts.URL,
+			StationID: []string{"KSUA"},
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		ts.Close()
+		require.Empty(t, acc.Errors)
+		require.Len(t, acc.Metrics, 1)
+
+		if expected == nil {
+			expected = acc.GetTelegrafMetrics()
+			continue
+		}
+		testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+	require.True(t, strings.HasPrefix(ts.URL, "http://127.0.0.1:"))
+
+	tests := []struct {
+		ipVersion string
+		err       bool
+	}{
+		{ipVersion: "auto"},
+		{ipVersion: "ipv4"},
+		{ipVersion: "ipv6", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ipVersion, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:     ts.URL,
+				StationID:   []string{"KSUA"},
+				IPVersion:   tt.ipVersion,
+				DialTimeout: config.Duration(time.Second),
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			if tt.err {
+				require.Len(t, acc.Errors, 1)
+				require.Empty(t, acc.Metrics)
+				return
+			}
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		IPVersion: "ipv5",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestConnectionPool(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		MaxParallel: 4,
+	}
+	require.NoError(t, n.Init())
+	transport, ok := n.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	require.Equal(t, 4, transport.MaxIdleConnsPerHost)
+	require.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+
+	n = &NOAAWeatherAPI{
+		MaxIdleConns:        20,
+		MaxIdleConnsPerHost: 5,
+		IdleConnTimeout:     config.Duration(time.Minute),
+	}
+	require.NoError(t, n.Init())
+	transport, ok = n.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, 20, transport.MaxIdleConns)
+	require.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	require.Equal(t, time.Minute, transport.IdleConnTimeout)
+
+	n = &NOAAWeatherAPI{
+		MaxIdleConnsPerHost: -1,
+	}
+	require.Error(t, n.Init())
+}
+
+func TestTLSCADir(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	// The certificate of the test server is a self-signed authority
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), ca, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0600))
+
+	for _, caDir := range []string{"", dir} {
+		t.Run(fmt.Sprintf("tls_ca_dir=%q", caDir), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:   ts.URL,
+				StationID: []string{"KSUA"},
+				TLSCADir:  caDir,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			if caDir == "" {
+				require.Len(t, acc.Errors, 1)
+				require.Contains(t, acc.Errors[0].Error(), "certificate")
+				return
+			}
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+		})
+	}
+
+	// The authorities are added to the TLS config of an injected transport
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		TLSCADir:  dir,
+		Transport: &http.Transport{},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+
+	n = &NOAAWeatherAPI{
+		TLSCADir:  dir,
+		Transport: roundTripFunc(http.DefaultTransport.RoundTrip),
+	}
+	require.Error(t, n.Init())
+
+	n = &NOAAWeatherAPI{
+		TLSCADir: t.TempDir(),
+	}
+	require.Error(t, n.Init())
+}
+
+func TestTimestampSource(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	now := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	tests := []struct {
+		source   string
+		expected time.Time
+	}{
+		{source: "", expected: time.Unix(1636311000, 0)},
+		{source: "observation", expected: time.Unix(1636311000, 0)},
+		{source: "collection", expected: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:         ts.URL,
+				StationID:       []string{"KSUA"},
+				TimestampSource: tt.source,
+			}
+			n.setNow(func() time.Time { return now })
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+			require.True(t, tt.expected.Equal(acc.Metrics[0].Time))
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		TimestampSource: "server",
+	}
+	require.Error(t, n.Init())
+
+	// All observations of the history would get the same timestamp
+	n = &NOAAWeatherAPI{
+		TimestampSource: "collection",
+		HistoryDuration: config.Duration(time.Hour),
+	}
+	require.Error(t, n.Init())
+}
+
+func TestStationTagKey(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:       ts.URL,
+		StationID:     []string{"KSUA"},
+		StationTagKey: "site",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, map[string]string{"site": "KSUA"}, acc.Metrics[0].Tags)
+
+	n = &NOAAWeatherAPI{
+		StationTagKey: "station_name",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestSourceTag(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	for _, source := range []string{"", "mesonet"} {
+		t.Run(source, func(t *testing.T) {
+			n := inputs.Inputs["noaa_weather_api"]().(*NOAAWeatherAPI)
+			n.BaseURL = ts.URL
+			n.StationID = []string{"KSUA"}
+			n.CollectStats = true
+			if source != "" {
+				n.SourceTag = source
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 2)
+
+			expected := "nws"
+			if source != "" {
+				expected = source
+			}
+			for _, m := range acc.Metrics {
+				require.Equal(t, expected, m.Tags["source"], m.Measurement)
+			}
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		StationTagKey: "source",
+	}
+	require.Error(t, n.Init())
+}
+
+func TestFile(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	// Derived metrics are added the same way as for queried stations
+	n := &NOAAWeatherAPI{
+		BaseURL:          ts.URL,
+		StationID:        []string{"KSUA"},
+		Units:            "metric",
+		EmitHealth:       true,
+		EmitUnitMetadata: true,
+	}
+	require.NoError(t, n.Init())
+	var expected testutil.Accumulator
+	require.NoError(t, n.Gather(&expected))
+	require.Empty(t, expected.Errors)
+
+	filename := filepath.Join(t.TempDir(), "observation.json")
+	require.NoError(t, os.WriteFile(filename, []byte(sampleStatusResponse), 0600))
+
+	n = &NOAAWeatherAPI{
+		File:             filename,
+		Units:            "metric",
+		EmitHealth:       true,
+		EmitUnitMetadata: true,
+	}
+	require.NoError(t, n.Init())
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.True(t, acc.HasMeasurement("noaa_weather_health"))
+	testutil.RequireMetricsEqual(t, expected.GetTelegrafMetrics(), acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+
+	n = &NOAAWeatherAPI{
+		File:                   filename,
+		IncludeStationMetadata: true,
+	}
+	require.Error(t, n.Init())
+
+	n = &NOAAWeatherAPI{
+		File: filepath.Join(t.TempDir(), "missing.json"),
+	}
+	require.NoError(t, n.Init())
+	acc = testutil.Accumulator{}
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+}
+
+func TestStationErrors(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA", "KFPR", "KVRB"},
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 1)
+
+	var errs []string
+	for _, err := range acc.Errors {
+		errs = append(errs, strings.SplitN(err.Error(), ":", 2)[0])
+	}
+	require.ElementsMatch(t, []string{"station KFPR", "station KVRB"}, errs)
+}
+
+func TestDedupAccumulator(t *testing.T) {
+	var acc testutil.Accumulator
+	dedup := &dedupAccumulator{Accumulator: &acc, seen: make(map[string]bool)}
+	dedup.AddError(fmt.Errorf("unknown unit code"))
+	dedup.AddError(fmt.Errorf("unknown unit code"))
+	dedup.AddError(fmt.Errorf("station KSUA returned no observation"))
+	dedup.AddError(nil)
+	require.Len(t, acc.Errors, 2)
+}
+
+func TestIncludeAge(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected float64
+	}{
+		{
+			name:     "hour old",
+			now:      time.Date(2021, 11, 7, 19, 50, 0, 0, time.UTC),
+			expected: 3600,
+		},
+		{
+			name:     "future",
+			now:      time.Date(2021, 11, 7, 18, 0, 0, 0, time.UTC),
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:    ts.URL,
+				StationID:  []string{"KSUA"},
+				IncludeAge: true,
+				Log:        testutil.Logger{},
+			}
+			n.setNow(func() time.Time { return tt.now })
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			age, ok := acc.FloatField("noaa_weather", "observation_age_seconds")
+			require.True(t, ok)
+			require.InDelta(t, tt.expected, age, 1e-9)
+		})
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	var language atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language.Store(r.Header.Get("Accept-Language"))
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		fmt.Fprint(w, sampleStatusResponse)
+	}))
+	defer ts.Close()
+
+	for _, tt := range []struct{ language, expected string }{
+		{language: "", expected: "en-US"},
+		{language: "es-US", expected: "es-US"},
+	} {
+		n := &NOAAWeatherAPI{
+			BaseURL:        ts.URL,
+			StationID:      []string{"KSUA"},
+			AcceptLanguage: tt.language,
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Empty(t, acc.Errors)
+		require.Equal(t, tt.expected, language.Load())
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		size config.Size
+		err  bool
+	}{
+		{size: 0},
+		{size: config.Size(len(sampleStatusResponse))},
+		{size: config.Size(len(sampleStatusResponse) / 2), err: true},
+		{size: 1024, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:     ts.URL,
+				StationID:   []string{"KSUA"},
+				MaxBodySize: tt.size,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			if tt.err {
+				require.Len(t, acc.Errors, 1)
+				require.Contains(t, acc.Errors[0].Error(), "response body exceeds max_body_size")
+				require.Empty(t, acc.Metrics)
+				return
+			}
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+		})
+	}
+}
+
+func TestGatherMetrics(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA", "KFPR"},
+		Units:     "metric",
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+
+	metrics, err := n.GatherMetrics(context.Background())
+	testutil.RequireMetricsEqual(t, acc.GetTelegrafMetrics(), metrics)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "station KFPR")
+	errs, ok := err.(GatherErrors)
+	require.True(t, ok)
+	require.Len(t, errs, 1)
+
+	// Nothing is requested with a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	metrics, err = n.GatherMetrics(ctx)
+	require.Empty(t, metrics)
+	require.Equal(t, GatherErrors{context.Canceled}, err)
+}
+
+func TestGatherMetricsCancel(t *testing.T) {
+	started := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stations/KSLW/observations/latest" {
+			close(started)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		StationID:       []string{"KSLW"},
+		EmitUnavailable: true,
+		CollectStats:    true,
+		MaxRetries:      3,
+		RetryOn:         []string{"network"},
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	start := time.Now()
+	metrics, err := n.GatherMetrics(ctx)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	require.Empty(t, metrics)
+	require.Equal(t, GatherErrors{context.Canceled}, err)
+}
+
+func TestEmitUnavailable(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		StationID:       []string{"KSUA", "KGONE"},
+		EmitUnavailable: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "404 Not Found")
+
+	availability := make(map[string]interface{})
+	for _, m := range acc.Metrics {
+		if m.Measurement == "noaa_weather_availability" {
+			availability[m.Tags["station"]] = m.Fields["available"]
+		}
+	}
+	require.Equal(t, map[string]interface{}{"KSUA": int64(1), "KGONE": int64(0)}, availability)
+}
+
+func TestEmitHealth(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		stations []string
+		expected int64
+	}{
+		{
+			name:     "healthy",
+			stations: []string{"KSUA", "KGONE"},
+			expected: 1,
+		},
+		{
+			name:     "all stations failing",
+			stations: []string{"KGONE", "KLOST"},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:    ts.URL,
+				StationID:  tt.stations,
+				EmitHealth: true,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.NotEmpty(t, acc.Errors)
+
+			var health []telegraf.Metric
+			for _, m := range acc.GetTelegrafMetrics() {
+				if m.Name() == "noaa_weather_health" {
+					health = append(health, m)
+				}
+			}
+			require.Len(t, health, 1)
+			require.Equal(t, telegraf.Gauge, health[0].Type())
+			require.Equal(t, map[string]interface{}{"plugin_healthy": tt.expected}, health[0].Fields())
+		})
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		failFast bool
+	}{
+		{name: "best effort"},
+		{name: "fail fast", failFast: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NOAAWeatherAPI{
+				BaseURL:   ts.URL,
+				StationID: []string{"KSUA", "KSLW"},
+				FailFast:  tt.failFast,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			err := n.Gather(&acc)
+			require.Len(t, acc.Metrics, 1)
+			require.Equal(t, "KSUA", acc.Metrics[0].Tags["station"])
+			if tt.failFast {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "KSLW")
+				require.Empty(t, acc.Errors)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, acc.Errors, 1)
+				require.Contains(t, acc.Errors[0].Error(), "KSLW")
+			}
+		})
+	}
+
+	// Diagnostics of stations gathered successfully do not fail the gather
+	rsp := strings.Replace(sampleStatusResponse, `"unitCode": "wmoUnit:degC"`, `"unitCode": "wmoUnit:degX"`, 1)
+	require.NotEqual(t, sampleStatusResponse, rsp)
+	ts = newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": rsp,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		FailFast:  true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "unknown unit code")
+	require.Len(t, acc.Metrics, 1)
 }
@@ -0,0 +1,99 @@
+package noaa_weather_api
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Keys the quality control flag of a value may be reported under; feeds
+// normalized by some proxies use "qc" instead of "qualityControl".
+const (
+	qcKeyQualityControl = "qualityControl"
+	qcKeyShort          = "qc"
+)
+
+// qcMissing flags a value as missing; the API reports such values as null
+// or zero.
+const qcMissing = "Z"
+
+// UnmarshalJSON decodes a value taking the quality control flag from the
+// "qualityControl" key, or from the "qc" key if the former is missing.
+// Unknown keys are ignored but remembered for strict_decoding, as the
+// settings of the decoder do not apply to custom unmarshalers.
+func (v *ApiValue) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		UnitCode       string   `json:"unitCode"`
+		Value          *float64 `json:"value"`
+		QualityControl string   `json:"qualityControl"`
+		QC             string   `json:"qc"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	v.unknownKey = nil
+	if err := dec.Decode(&raw); err != nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		v.unknownKey = err
+	}
+
+	v.UnitCode = raw.UnitCode
+	v.Value = raw.Value
+	v.QualityControl = raw.QualityControl
+	if v.QualityControl == "" {
+		v.QualityControl = raw.QC
+	}
+	v.qc = raw.QC
+	return nil
+}
+
+// preferQC uses the flag of the "qc" key if the value carries both keys
+// and key is "qc".
+func (v *ApiValue) preferQC(key string) {
+	if key == qcKeyShort && v.qc != "" {
+		v.QualityControl = v.qc
+	}
+}
+
+// applyQC applies the configured quality control key to all values of the
+// observation and nulls the values flagged as missing, whatever number the
+// API reported for them.
+func (s *Status) applyQC(key string) {
+	for _, v := range s.apiValues() {
+		v.preferQC(key)
+		if v.QualityControl == qcMissing {
+			v.Value = nil
+		}
+	}
+}
+
+// unknownKey returns the error for the first key unknown to a value of the
+// observation, including the bases of its cloud layers.
+func (s *Status) unknownKey() error {
+	values := s.apiValues()
+	for i := range s.CloudLayers {
+		values = append(values, &s.CloudLayers[i].Base)
+	}
+	for _, v := range values {
+		if v.unknownKey != nil {
+			return v.unknownKey
+		}
+	}
+	return nil
+}
+
+// apiValues returns the measured values of the observation.
+func (s *Status) apiValues() []*ApiValue {
+	return []*ApiValue{
+		&s.Temperature,
+		&s.Humidity,
+		&s.BarometricPressure,
+		&s.Visibility,
+		&s.WindSpeed,
+		&s.WindDirection,
+		&s.Dewpoint,
+		&s.HeatIndex,
+		&s.WindChill,
+		&s.SeaLevelPressure,
+	}
+}
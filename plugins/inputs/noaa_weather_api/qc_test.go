@@ -0,0 +1,100 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQCJSONKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		expected string
+	}{
+		{
+			name:     "qualityControl",
+			value:    `{"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "V"}`,
+			expected: "V",
+		},
+		{
+			name:     "qc fallback",
+			value:    `{"unitCode": "wmoUnit:degC", "value": 21, "qc": "V"}`,
+			expected: "V",
+		},
+		{
+			name:     "both keys",
+			value:    `{"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "S", "qc": "V"}`,
+			expected: "S",
+		},
+		{
+			name:     "both keys prefer qc",
+			key:      "qc",
+			value:    `{"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "S", "qc": "V"}`,
+			expected: "V",
+		},
+		{
+			name:     "prefer qc fallback",
+			key:      "qc",
+			value:    `{"unitCode": "wmoUnit:degC", "value": 21, "qualityControl": "V"}`,
+			expected: "V",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newStationServer(t, map[string]string{
+				"/stations/KSUA/observations/latest": `{"station": "https://api.weather.gov/stations/KSUA", "temperature": ` + tt.value + `}`,
+			})
+			defer ts.Close()
+
+			n := &NOAAWeatherAPI{
+				BaseURL:   ts.URL,
+				StationID: []string{"KSUA"},
+				QCJSONKey: tt.key,
+			}
+			require.NoError(t, n.Init())
+
+			status, err := n.gatherURL(context.Background(), n.formatURL(n.ObservationPath, "KSUA"))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, status.Temperature.QualityControl)
+			require.Equal(t, 21.0, *status.Temperature.Value)
+			require.Equal(t, "wmoUnit:degC", status.Temperature.UnitCode)
+		})
+	}
+
+	n := &NOAAWeatherAPI{QCJSONKey: "quality"}
+	err := n.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "qc_json_key")
+}
+
+func TestQCMissing(t *testing.T) {
+	rsp := strings.Replace(sampleStatusResponse, `"value": 340,
+    "qualityControl": "V"`, `"value": 0,
+    "qualityControl": "Z"`, 1)
+	require.NotEqual(t, sampleStatusResponse, rsp)
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": rsp,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:             ts.URL,
+		StationID:           []string{"KSUA"},
+		IncludeWindCardinal: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.NotContains(t, acc.Metrics[0].Fields, "wind_degrees")
+	require.NotContains(t, acc.Metrics[0].Fields, "wind_cardinal")
+	require.Contains(t, acc.Metrics[0].Fields, "temperature")
+}
@@ -0,0 +1,63 @@
+package noaa_weather_api
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests evenly so that no more than the configured
+// number of requests per second are started.
+type rateLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// reserve returns how long to wait from now until the next request may be
+// started. Each call reserves a slot, so concurrent callers are served in
+// the order they arrive.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return delay
+}
+
+// sharedLimiterKey identifies a limiter shared between plugin instances.
+// The rate is part of the key so that a rate_limit changed on a config
+// reload takes effect instead of reusing the limiter of the old config.
+type sharedLimiterKey struct {
+	key  string
+	rate float64
+}
+
+// Limiters shared by all plugin instances with the same rate_limit_key and
+// rate_limit. Instances are gathered concurrently by the agent, so the
+// limiters are only ever accessed through their own lock; the map itself
+// is written once per key when the first instance is initialized.
+var sharedRateLimiters sync.Map
+
+// initRateLimiter sets up the limiter of the instance, shared with the
+// other instances using the same rate_limit_key and rate_limit.
+func (n *NOAAWeatherAPI) initRateLimiter() {
+	if n.RateLimit <= 0 {
+		return
+	}
+	limiter := newRateLimiter(n.RateLimit)
+	if n.RateLimitKey == "" {
+		n.limiter = limiter
+		return
+	}
+
+	shared, _ := sharedRateLimiters.LoadOrStore(sharedLimiterKey{n.RateLimitKey, n.RateLimit}, limiter)
+	n.limiter = shared.(*rateLimiter)
+}
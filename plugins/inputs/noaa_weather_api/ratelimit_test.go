@@ -0,0 +1,109 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/ld+json"}
+		_, err := fmt.Fprint(w, sampleStatusResponse)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	// The clock stands still, so the waits are the offsets of the slots
+	// reserved by the requests.
+	var mu sync.Mutex
+	var waits []time.Duration
+	start := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+
+	// 20 requests per second shared by both instances. The limiter is
+	// dropped afterwards so that repeated runs start with a free slot.
+	interval := 50 * time.Millisecond
+	t.Cleanup(func() { sharedRateLimiters.Delete(sharedLimiterKey{t.Name(), 20}) })
+	instances := make([]*NOAAWeatherAPI, 0, 2)
+	for _, stations := range [][]string{{"KSUA", "KFPR"}, {"KVRB", "KPBI"}} {
+		n := &NOAAWeatherAPI{
+			BaseURL:      ts.URL,
+			StationID:    stations,
+			RateLimit:    20,
+			RateLimitKey: t.Name(),
+		}
+		n.setNow(func() time.Time { return start })
+		n.setSleep(func(_ context.Context, d time.Duration) error {
+			mu.Lock()
+			waits = append(waits, d)
+			mu.Unlock()
+			return nil
+		})
+		require.NoError(t, n.Init())
+		instances = append(instances, n)
+	}
+	require.Same(t, instances[0].limiter, instances[1].limiter)
+
+	var wg sync.WaitGroup
+	for _, n := range instances {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+		}()
+	}
+	wg.Wait()
+
+	require.ElementsMatch(t, []time.Duration{0, interval, 2 * interval, 3 * interval}, waits)
+
+	// Instances without a key are limited on their own
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KSUA"},
+		RateLimit: 20,
+	}
+	require.NoError(t, n.Init())
+	require.NotSame(t, instances[0].limiter, n.limiter)
+
+	// A changed rate, e.g. after a config reload, gets its own limiter
+	n = &NOAAWeatherAPI{
+		BaseURL:      ts.URL,
+		StationID:    []string{"KSUA"},
+		RateLimit:    10,
+		RateLimitKey: t.Name(),
+	}
+	require.NoError(t, n.Init())
+	t.Cleanup(func() { sharedRateLimiters.Delete(sharedLimiterKey{t.Name(), 10}) })
+	require.NotSame(t, instances[0].limiter, n.limiter)
+	require.Equal(t, 100*time.Millisecond, n.limiter.interval)
+
+	n = &NOAAWeatherAPI{
+		RateLimit: -1,
+	}
+	require.Error(t, n.Init())
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	require.Equal(t, 10*time.Millisecond, l.interval)
+
+	start := time.Date(2021, 11, 7, 19, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.Equal(t, time.Duration(i)*l.interval, l.reserve(start))
+	}
+
+	// Slots that passed unused are not made up for
+	later := start.Add(time.Second)
+	require.Equal(t, time.Duration(0), l.reserve(later))
+	require.Equal(t, l.interval, l.reserve(later))
+}
@@ -0,0 +1,114 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// doWithRetry sends the request and retries it with exponential backoff
+// when it fails with a transient error. Only GET requests are retried, as
+// they are idempotent, and retries count against the request budget.
+func (n *NOAAWeatherAPI) doWithRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if n.limiter != nil {
+			if err := n.sleep(req.Context(), n.limiter.reserve(n.now())); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := n.client.Do(req)
+		if attempt >= n.MaxRetries || req.Method != http.MethodGet || req.Context().Err() != nil ||
+			!n.retryable(resp, err) || !n.takeRequest() {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := n.sleep(req.Context(), n.retryDelay(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryTruncated calls fetch and retries it with exponential backoff while
+// the response it received was truncated.
+func (n *NOAAWeatherAPI) retryTruncated(ctx context.Context, fetch func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fetch()
+		if attempt >= n.MaxRetries || !errors.Is(err, errTruncatedResponse) {
+			return err
+		}
+		if err := n.sleep(ctx, n.retryDelay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// Failures retry_on can select: connection errors, rate limiting and
+// server errors.
+const (
+	retryOnNetwork     = "network"
+	retryOnServerError = "5xx"
+	retryOnRateLimit   = "429"
+)
+
+var defaultRetryOn = []string{retryOnRateLimit, retryOnServerError}
+
+// initRetryOn validates retry_on, defaulting to rate limits and server
+// errors.
+func (n *NOAAWeatherAPI) initRetryOn() error {
+	if len(n.RetryOn) == 0 {
+		n.RetryOn = defaultRetryOn
+	}
+	n.retryOn = make(map[string]bool, len(n.RetryOn))
+	for _, failure := range n.RetryOn {
+		switch failure {
+		case retryOnNetwork, retryOnServerError, retryOnRateLimit:
+			n.retryOn[failure] = true
+		default:
+			return fmt.Errorf("unknown failure in retry_on: %s", failure)
+		}
+	}
+	return nil
+}
+
+// retryable returns true if the request failed in a way that may succeed
+// when retried and retry_on selects the failure.
+func (n *NOAAWeatherAPI) retryable(resp *http.Response, err error) bool {
+	switch {
+	case err != nil:
+		return n.retryOn[retryOnNetwork]
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return n.retryOn[retryOnRateLimit]
+	case resp.StatusCode >= 500:
+		return n.retryOn[retryOnServerError]
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the backoff before the given retry attempt. The delay
+// doubles with each attempt up to retry_max_delay, and half of it is
+// randomized so that many stations failing at once do not retry in lockstep.
+func (n *NOAAWeatherAPI) retryDelay(attempt int) time.Duration {
+	delay := time.Duration(n.RetryBaseDelay)
+	for i := 0; i < attempt && delay < time.Duration(n.RetryMaxDelay); i++ {
+		delay *= 2
+	}
+	if delay > time.Duration(n.RetryMaxDelay) {
+		delay = time.Duration(n.RetryMaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
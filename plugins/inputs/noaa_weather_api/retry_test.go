@@ -0,0 +1,285 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransientErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		maxRetries int
+		attempts   int
+		success    bool
+	}{
+		{
+			name:       "server error recovers",
+			status:     http.StatusBadGateway,
+			maxRetries: 3,
+			attempts:   3,
+			success:    true,
+		},
+		{
+			name:       "rate limit recovers",
+			status:     http.StatusTooManyRequests,
+			maxRetries: 3,
+			attempts:   3,
+			success:    true,
+		},
+		{
+			name:       "retries exhausted",
+			status:     http.StatusServiceUnavailable,
+			maxRetries: 1,
+			attempts:   2,
+		},
+		{
+			name:       "client error not retried",
+			status:     http.StatusNotFound,
+			maxRetries: 3,
+			attempts:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= 2 {
+					w.WriteHeader(tt.status)
+					return
+				}
+
+				w.Header()["Content-Type"] = []string{"application/ld+json"}
+				_, err := fmt.Fprint(w, sampleStatusResponse)
+				require.NoError(t, err)
+			}))
+			defer ts.Close()
+
+			n := &NOAAWeatherAPI{
+				BaseURL:        ts.URL,
+				StationID:      []string{"KSUA"},
+				MaxRetries:     tt.maxRetries,
+				RetryBaseDelay: config.Duration(time.Millisecond),
+				RetryMaxDelay:  config.Duration(5 * time.Millisecond),
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+
+			require.Equal(t, tt.attempts, attempts)
+			if tt.success {
+				require.Empty(t, acc.Errors)
+				require.Len(t, acc.Metrics, 1)
+			} else {
+				require.Len(t, acc.Errors, 1)
+				require.Empty(t, acc.Metrics)
+			}
+		})
+	}
+}
+
+func TestRetryOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		retryOn  []string
+		status   int
+		attempts int
+	}{
+		{name: "default server error", status: http.StatusInternalServerError, attempts: 2},
+		{name: "default network", attempts: 1},
+		{name: "server error", retryOn: []string{"5xx"}, status: http.StatusInternalServerError, attempts: 2},
+		{name: "server error not selected", retryOn: []string{"network", "429"}, status: http.StatusInternalServerError, attempts: 1},
+		{name: "rate limit not selected", retryOn: []string{"5xx"}, status: http.StatusTooManyRequests, attempts: 1},
+		{name: "network", retryOn: []string{"network"}, attempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			n := &NOAAWeatherAPI{
+				BaseURL:        "https://api.weather.gov",
+				StationID:      []string{"KSUA"},
+				MaxRetries:     1,
+				RetryOn:        tt.retryOn,
+				RetryBaseDelay: config.Duration(time.Millisecond),
+				RetryMaxDelay:  config.Duration(time.Millisecond),
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					if tt.status == 0 {
+						return nil, errors.New("connection refused")
+					}
+					return &http.Response{
+						StatusCode: tt.status,
+						Status:     http.StatusText(tt.status),
+						Header:     http.Header{},
+						Body:       io.NopCloser(strings.NewReader("")),
+						Request:    req,
+					}, nil
+				}),
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Len(t, acc.Errors, 1)
+			require.Equal(t, tt.attempts, attempts)
+		})
+	}
+
+	n := &NOAAWeatherAPI{
+		RetryOn: []string{"4xx"},
+	}
+	require.Error(t, n.Init())
+}
+
+func TestRetryCancel(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		BaseURL:        "https://api.weather.gov",
+		StationID:      []string{"KSUA"},
+		MaxRetries:     3,
+		RetryBaseDelay: config.Duration(time.Hour),
+		RetryMaxDelay:  config.Duration(time.Hour),
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Status:     http.StatusText(http.StatusServiceUnavailable),
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}),
+	}
+	require.NoError(t, n.Init())
+
+	// The backoff is aborted instead of delaying the gather by an hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	metrics, err := n.GatherMetrics(ctx)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Empty(t, metrics)
+	require.Equal(t, GatherErrors{context.DeadlineExceeded}, err)
+}
+
+// roundTripFunc is a fake transport answering requests with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransport(t *testing.T) {
+	var attempts int
+	n := &NOAAWeatherAPI{
+		BaseURL:        "https://api.weather.gov",
+		StationID:      []string{"KSUA"},
+		MaxRetries:     1,
+		RetryBaseDelay: config.Duration(time.Millisecond),
+		RetryMaxDelay:  config.Duration(time.Millisecond),
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			require.Equal(t, "/stations/KSUA/observations/latest", req.URL.Path)
+			if attempts == 1 {
+				return &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Status:     "429 Too Many Requests",
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     "200 OK",
+				Header:     http.Header{"Content-Type": []string{"application/ld+json"}},
+				Body:       io.NopCloser(strings.NewReader(sampleStatusResponse)),
+				Request:    req,
+			}, nil
+		}),
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, 2, attempts)
+}
+
+func TestRetryDelay(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		RetryBaseDelay: config.Duration(100 * time.Millisecond),
+		RetryMaxDelay:  config.Duration(time.Second),
+	}
+
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		delay := n.retryDelay(attempt)
+		require.GreaterOrEqual(t, delay, max/2)
+		require.LessOrEqual(t, delay, max)
+	}
+}
+
+func TestRetryTruncatedResponse(t *testing.T) {
+	for _, recovers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("recovers=%v", recovers), func(t *testing.T) {
+			var attempts int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				rsp := sampleStatusResponse
+				if !recovers || attempts == 1 {
+					rsp = rsp[:len(rsp)/2]
+				}
+
+				w.Header()["Content-Type"] = []string{"application/ld+json"}
+				_, err := fmt.Fprint(w, rsp)
+				require.NoError(t, err)
+			}))
+			defer ts.Close()
+
+			n := &NOAAWeatherAPI{
+				BaseURL:        ts.URL,
+				StationID:      []string{"KSUA"},
+				MaxRetries:     2,
+				RetryBaseDelay: config.Duration(time.Millisecond),
+				RetryMaxDelay:  config.Duration(5 * time.Millisecond),
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+
+			if recovers {
+				require.Equal(t, 2, attempts)
+				require.Empty(t, acc.Errors)
+				require.Len(t, acc.Metrics, 1)
+				return
+			}
+			require.Equal(t, 3, attempts)
+			require.Len(t, acc.Errors, 1)
+			require.Contains(t, acc.Errors[0].Error(), "station KSUA")
+			require.Contains(t, acc.Errors[0].Error(), "truncated")
+			require.Empty(t, acc.Metrics)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+)
+
+// Plausible ranges of the observed values in the units returned by the API,
+// values outside are considered sensor faults.
+var defaultSanityLimits = map[string][]float64{
+	"temperature":  {-90, 60},
+	"dewpoint":     {-90, 60},
+	"humidity":     {0, 100},
+	"pressure":     {80000, 110000},
+	"wind_speed":   {0, 400},
+	"wind_degrees": {0, 360},
+	"visibility":   {0, 200000},
+}
+
+// initSanityLimits merges the configured limits into the defaults and
+// validates them.
+func (n *NOAAWeatherAPI) initSanityLimits() error {
+	if !n.SanityChecks {
+		return nil
+	}
+
+	limits := make(map[string][]float64, len(defaultSanityLimits))
+	for name, limit := range defaultSanityLimits {
+		limits[name] = limit
+	}
+
+	known := (&Status{}).values()
+	for name, limit := range n.SanityLimits {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown field in sanity_limits: %s", name)
+		}
+		if len(limit) != 2 || limit[0] > limit[1] {
+			return fmt.Errorf("sanity_limits of %s must be [min, max]", name)
+		}
+		limits[name] = limit
+	}
+	n.SanityLimits = limits
+	return nil
+}
+
+// sane checks the value of the field against the sanity limits, counting
+// and logging values out of range.
+func (n *NOAAWeatherAPI) sane(station string, name string, value float64) bool {
+	if !n.SanityChecks {
+		return true
+	}
+	// Slightly too high humidity values are clamped instead.
+	if name == "humidity" && n.ClampHumidity {
+		return true
+	}
+
+	limit, ok := n.SanityLimits[name]
+	if !ok || (value >= limit[0] && value <= limit[1]) {
+		return true
+	}
+
+	n.statsLock.Lock()
+	n.statsFor(station).sanityDropped++
+	n.statsLock.Unlock()
+	n.Log.Warnf("Dropping %s %v of station %s outside of [%v, %v]", name, value, station, limit[0], limit[1])
+	return false
+}
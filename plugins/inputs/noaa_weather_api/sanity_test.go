@@ -0,0 +1,68 @@
+package noaa_weather_api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanityChecks(t *testing.T) {
+	absurd := strings.Replace(sampleSparseResponse, `"value": 21,`, `"value": 500,`, 1)
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": absurd,
+	})
+	defer ts.Close()
+
+	for _, enabled := range []bool{false, true} {
+		n := &NOAAWeatherAPI{
+			BaseURL:      ts.URL,
+			StationID:    []string{"KSUA"},
+			Units:        "metric",
+			SanityChecks: enabled,
+			CollectStats: true,
+			Log:          testutil.Logger{},
+		}
+		require.NoError(t, n.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, n.Gather(&acc))
+		require.Empty(t, acc.Errors)
+
+		m, ok := acc.Get("noaa_weather")
+		require.True(t, ok)
+		require.Equal(t, !enabled, m.Fields["temperature"] != nil)
+		require.Contains(t, m.Fields, "pressure")
+
+		internal, ok := acc.Get("noaa_weather_internal")
+		require.True(t, ok)
+		if enabled {
+			require.Equal(t, int64(1), internal.Fields["sanity_dropped_total"])
+		} else {
+			require.NotContains(t, internal.Fields, "sanity_dropped_total")
+		}
+	}
+}
+
+func TestSanityLimits(t *testing.T) {
+	n := &NOAAWeatherAPI{
+		SanityChecks: true,
+		SanityLimits: map[string][]float64{"temperature": {-10, 10}},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, []float64{-10, 10}, n.SanityLimits["temperature"])
+	require.Equal(t, defaultSanityLimits["pressure"], n.SanityLimits["pressure"])
+
+	for _, limits := range []map[string][]float64{
+		{"temp": {-10, 10}},
+		{"temperature": {10}},
+		{"temperature": {10, -10}},
+	} {
+		n := &NOAAWeatherAPI{
+			SanityChecks: true,
+			SanityLimits: limits,
+		}
+		require.Error(t, n.Init())
+	}
+}
@@ -0,0 +1,155 @@
+package noaa_weather_api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StationCollection is the GeoJSON feature collection returned by the
+// endpoints listing stations.
+type StationCollection struct {
+	Features []struct {
+		Properties struct {
+			StationIdentifier string `json:"stationIdentifier"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// addZoneStations appends the stations of the configured forecast zone to
+// the explicitly configured ones, skipping stations already present. The
+// stations are validated like the configured ones.
+func (n *NOAAWeatherAPI) addZoneStations() error {
+	addr := n.resolveURL(fmt.Sprintf("/zones/forecast/%s/stations", url.PathEscape(n.ZoneID)), nil)
+
+	stations, err := n.gatherStationList(addr)
+	if err != nil {
+		return fmt.Errorf("getting stations of zone %s failed: %s", n.ZoneID, err)
+	}
+	if len(stations) > n.MaxZoneStations {
+		n.Log.Warnf("Zone %s has %d stations, only gathering the first %d", n.ZoneID, len(stations), n.MaxZoneStations)
+		stations = stations[:n.MaxZoneStations]
+	}
+	if err := validateStations(stations); err != nil {
+		return fmt.Errorf("getting stations of zone %s failed: %s", n.ZoneID, err)
+	}
+
+	n.StationID = mergeStations(n.StationID, stations)
+	return nil
+}
+
+// addQueryStations appends the stations matching station_query, such as
+// the stations of a state or network, to the configured ones, skipping
+// stations already present. The stations are validated like the
+// configured ones.
+func (n *NOAAWeatherAPI) addQueryStations() error {
+	v, err := url.ParseQuery(strings.TrimPrefix(n.StationQuery, "?"))
+	if err != nil {
+		return fmt.Errorf("invalid station_query %q: %s", n.StationQuery, err)
+	}
+	if v.Get("limit") == "" {
+		v.Set("limit", strconv.Itoa(n.StationQueryLimit))
+	}
+	addr := n.resolveURL("/stations", v)
+
+	stations, err := n.gatherStationList(addr)
+	if err != nil {
+		return fmt.Errorf("getting stations of query %s failed: %s", n.StationQuery, err)
+	}
+	if len(stations) > n.StationQueryLimit {
+		n.Log.Warnf("Query %s matches %d stations, only gathering the first %d", n.StationQuery, len(stations), n.StationQueryLimit)
+		stations = stations[:n.StationQueryLimit]
+	}
+	if err := validateStations(stations); err != nil {
+		return fmt.Errorf("getting stations of query %s failed: %s", n.StationQuery, err)
+	}
+
+	n.StationID = mergeStations(n.StationID, stations)
+	return nil
+}
+
+// loadStationFile reads station_file and merges its stations with the
+// stations of station_id and the zone. On error the current stations are
+// kept.
+func (n *NOAAWeatherAPI) loadStationFile() error {
+	stations, err := readStationFile(n.StationFile)
+	if err != nil {
+		return fmt.Errorf("reading station_file %s failed: %s", n.StationFile, err)
+	}
+	if err := validateStations(stations); err != nil {
+		return fmt.Errorf("reading station_file %s failed: %s", n.StationFile, err)
+	}
+
+	n.StationID = mergeStations(append([]string{}, n.staticStations...), stations)
+	n.stationFileRead = n.now()
+	return nil
+}
+
+// readStationFile returns the station identifiers listed one per line in
+// the file. Blank lines and comments starting with "#" are ignored.
+func readStationFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stations []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			stations = append(stations, line)
+		}
+	}
+	return stations, scanner.Err()
+}
+
+// mergeStations appends the additional stations not yet in stations.
+func mergeStations(stations []string, additional []string) []string {
+	seen := make(map[string]bool, len(stations))
+	for _, station := range stations {
+		seen[station] = true
+	}
+	for _, station := range additional {
+		if station == "" || seen[station] {
+			continue
+		}
+		seen[station] = true
+		stations = append(stations, station)
+	}
+	return stations
+}
+
+func (n *NOAAWeatherAPI) gatherStationList(addr string) ([]string, error) {
+	resp, err := n.request(context.Background(), addr, geoJSONMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeStationList(resp.Body)
+}
+
+func decodeStationList(r io.Reader) ([]string, error) {
+	dec := json.NewDecoder(r)
+	collection := &StationCollection{}
+	if err := dec.Decode(collection); err != nil {
+		return nil, fmt.Errorf("error while decoding JSON response: %s", err)
+	}
+
+	stations := make([]string, 0, len(collection.Features))
+	for _, feature := range collection.Features {
+		stations = append(stations, feature.Properties.StationIdentifier)
+	}
+	return stations, nil
+}
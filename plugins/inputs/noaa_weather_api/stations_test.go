@@ -0,0 +1,287 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleZoneStationsResponse = `
+{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "id": "https://api.weather.gov/stations/KSUA",
+      "type": "Feature",
+      "properties": {
+        "@id": "https://api.weather.gov/stations/KSUA",
+        "@type": "wx:ObservationStation",
+        "stationIdentifier": "KSUA",
+        "name": "Stuart, Witham Field"
+      }
+    },
+    {
+      "id": "https://api.weather.gov/stations/KFPR",
+      "type": "Feature",
+      "properties": {
+        "@id": "https://api.weather.gov/stations/KFPR",
+        "@type": "wx:ObservationStation",
+        "stationIdentifier": "KFPR",
+        "name": "Fort Pierce, St. Lucie County International Airport"
+      }
+    },
+    {
+      "id": "https://api.weather.gov/stations/KVRB",
+      "type": "Feature",
+      "properties": {
+        "@id": "https://api.weather.gov/stations/KVRB",
+        "@type": "wx:ObservationStation",
+        "stationIdentifier": "KVRB",
+        "name": "Vero Beach Municipal Airport"
+      }
+    }
+  ]
+}
+`
+
+func newZoneServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/zones/forecast/FLZ164/stations":
+			rsp = sampleZoneStationsResponse
+			w.Header()["Content-Type"] = []string{"application/geo+json"}
+		case "/stations/KSUA/observations/latest",
+			"/stations/KFPR/observations/latest",
+			"/stations/KVRB/observations/latest":
+			rsp = sampleStatusResponse
+			w.Header()["Content-Type"] = []string{"application/ld+json"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+}
+
+func TestZoneStations(t *testing.T) {
+	ts := newZoneServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:   ts.URL,
+		StationID: []string{"KFPR"},
+		ZoneID:    "FLZ164",
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, []string{"KFPR", "KSUA", "KVRB"}, n.StationID)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	var stations []string
+	for _, m := range acc.Metrics {
+		stations = append(stations, m.Tags["station"])
+	}
+	require.ElementsMatch(t, []string{"KSUA", "KFPR", "KVRB"}, stations)
+}
+
+func TestZoneStationsLimit(t *testing.T) {
+	ts := newZoneServer(t)
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:         ts.URL,
+		ZoneID:          "FLZ164",
+		MaxZoneStations: 2,
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, []string{"KSUA", "KFPR"}, n.StationID)
+}
+
+func TestStationQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/stations":
+			require.Equal(t, "FL", r.URL.Query().Get("state"))
+			require.Equal(t, "2", r.URL.Query().Get("limit"))
+			rsp = sampleZoneStationsResponse
+			w.Header()["Content-Type"] = []string{"application/geo+json"}
+		case "/stations/KSUA/observations/latest",
+			"/stations/KFPR/observations/latest",
+			"/stations/KMLB/observations/latest":
+			rsp = sampleStatusResponse
+			w.Header()["Content-Type"] = []string{"application/ld+json"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		_, err := fmt.Fprint(w, rsp)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:           ts.URL,
+		StationID:         []string{"KMLB", "KSUA"},
+		StationQuery:      "state=FL",
+		StationQueryLimit: 2,
+		Log:               testutil.Logger{},
+	}
+	require.NoError(t, n.Init())
+	require.Equal(t, []string{"KMLB", "KSUA", "KFPR"}, n.StationID)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	var stations []string
+	for _, m := range acc.Metrics {
+		stations = append(stations, m.Tags["station"])
+	}
+	require.ElementsMatch(t, []string{"KMLB", "KSUA", "KFPR"}, stations)
+
+	n = &NOAAWeatherAPI{
+		BaseURL:      ts.URL,
+		StationQuery: "state=%zz",
+	}
+	err := n.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "station_query")
+}
+
+func TestResolvedStationsValidation(t *testing.T) {
+	const duplicates = `{"features": [
+  {"properties": {"stationIdentifier": "KSUA"}},
+  {"properties": {"stationIdentifier": "KFPR"}},
+  {"properties": {"stationIdentifier": "KSUA"}}
+]}`
+	const invalid = `{"features": [
+  {"properties": {"stationIdentifier": "KSUA"}},
+  {"properties": {"stationIdentifier": "../KFPR"}}
+]}`
+
+	tests := []struct {
+		name     string
+		response string
+		plugin   *NOAAWeatherAPI
+		expected []string
+	}{
+		{
+			name:     "zone duplicates",
+			response: duplicates,
+			plugin:   &NOAAWeatherAPI{StationID: []string{"KFPR"}, ZoneID: "FLZ164"},
+			expected: []string{"KFPR", "KSUA"},
+		},
+		{
+			name:     "zone invalid",
+			response: invalid,
+			plugin:   &NOAAWeatherAPI{ZoneID: "FLZ164"},
+		},
+		{
+			name:     "query duplicates",
+			response: duplicates,
+			plugin:   &NOAAWeatherAPI{StationID: []string{"KFPR"}, StationQuery: "state=FL"},
+			expected: []string{"KFPR", "KSUA"},
+		},
+		{
+			name:     "query invalid",
+			response: invalid,
+			plugin:   &NOAAWeatherAPI{StationQuery: "state=FL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{"application/geo+json"}
+				_, err := fmt.Fprint(w, tt.response)
+				require.NoError(t, err)
+			}))
+			defer ts.Close()
+
+			n := tt.plugin
+			n.BaseURL = ts.URL
+			n.Log = testutil.Logger{}
+			err := n.Init()
+			if tt.expected == nil {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `"../KFPR"`)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, n.StationID)
+		})
+	}
+}
+
+func TestStationFile(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+		"/stations/KFPR/observations/latest": sampleStatusResponse,
+		"/stations/KVRB/observations/latest": sampleStatusResponse,
+		"/stations/KPBI/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "stations.txt")
+	content := "# Treasure Coast\nKSUA\n\nKFPR # Fort Pierce\n  KVRB\n"
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+
+	now := time.Date(2021, 11, 7, 12, 0, 0, 0, time.UTC)
+	n := &NOAAWeatherAPI{
+		BaseURL:            ts.URL,
+		StationID:          []string{"KSUA"},
+		StationFile:        filename,
+		StationFileRefresh: config.Duration(time.Hour),
+	}
+	n.setNow(func() time.Time { return now })
+	require.NoError(t, n.Init())
+	require.Equal(t, []string{"KSUA", "KFPR", "KVRB"}, n.StationID)
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	var stations []string
+	for _, m := range acc.Metrics {
+		stations = append(stations, m.Tags["station"])
+	}
+	require.ElementsMatch(t, []string{"KSUA", "KFPR", "KVRB"}, stations)
+
+	// The file is only read again after the refresh interval
+	require.NoError(t, os.WriteFile(filename, []byte("KPBI\n"), 0600))
+	require.NoError(t, n.Gather(&acc))
+	require.Equal(t, []string{"KSUA", "KFPR", "KVRB"}, n.StationID)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, n.Gather(&acc))
+	require.Equal(t, []string{"KSUA", "KPBI"}, n.StationID)
+
+	// Invalid files are reported and the stations kept
+	require.NoError(t, os.WriteFile(filename, []byte("K/PBI\n"), 0600))
+	now = now.Add(time.Hour)
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.Contains(t, acc.Errors[0].Error(), "station_file")
+	require.Equal(t, []string{"KSUA", "KPBI"}, n.StationID)
+
+	n = &NOAAWeatherAPI{
+		StationFile: filepath.Join(t.TempDir(), "missing.txt"),
+	}
+	require.Error(t, n.Init())
+}
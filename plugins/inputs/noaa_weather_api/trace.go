@@ -0,0 +1,106 @@
+package noaa_weather_api
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace logs the timings of the phases of a request.
+type requestTrace struct {
+	sync.Mutex
+
+	n     *NOAAWeatherAPI
+	addr  string
+	start time.Time
+
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+}
+
+// traceContext returns a context logging the DNS, connect, TLS handshake
+// and first byte timings of the request to addr at debug level.
+func (n *NOAAWeatherAPI) traceContext(ctx context.Context, addr string) context.Context {
+	t := &requestTrace{
+		n:            n,
+		addr:         addr,
+		start:        n.now(),
+		connectStart: make(map[string]time.Time),
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:             t.dnsStarted,
+		DNSDone:              t.dnsDone,
+		ConnectStart:         t.connectStarted,
+		ConnectDone:          t.connectDone,
+		TLSHandshakeStart:    t.tlsStarted,
+		TLSHandshakeDone:     t.tlsDone,
+		GotConn:              t.gotConn,
+		GotFirstResponseByte: t.gotFirstResponseByte,
+	})
+}
+
+func (t *requestTrace) dnsStarted(httptrace.DNSStartInfo) {
+	t.Lock()
+	t.dnsStart = t.n.now()
+	t.Unlock()
+}
+
+func (t *requestTrace) dnsDone(info httptrace.DNSDoneInfo) {
+	t.Lock()
+	elapsed := t.n.now().Sub(t.dnsStart)
+	t.Unlock()
+	if info.Err != nil {
+		t.n.Log.Debugf("Request to %s: DNS lookup failed after %s: %s", t.addr, elapsed, info.Err)
+		return
+	}
+	t.n.Log.Debugf("Request to %s: DNS lookup took %s", t.addr, elapsed)
+}
+
+// Connections to several addresses may be attempted in parallel, so the
+// start is tracked per address.
+func (t *requestTrace) connectStarted(_, address string) {
+	t.Lock()
+	t.connectStart[address] = t.n.now()
+	t.Unlock()
+}
+
+func (t *requestTrace) connectDone(_, address string, err error) {
+	t.Lock()
+	elapsed := t.n.now().Sub(t.connectStart[address])
+	t.Unlock()
+	if err != nil {
+		t.n.Log.Debugf("Request to %s: connecting to %s failed after %s: %s", t.addr, address, elapsed, err)
+		return
+	}
+	t.n.Log.Debugf("Request to %s: connecting to %s took %s", t.addr, address, elapsed)
+}
+
+func (t *requestTrace) tlsStarted() {
+	t.Lock()
+	t.tlsStart = t.n.now()
+	t.Unlock()
+}
+
+func (t *requestTrace) tlsDone(_ tls.ConnectionState, err error) {
+	t.Lock()
+	elapsed := t.n.now().Sub(t.tlsStart)
+	t.Unlock()
+	if err != nil {
+		t.n.Log.Debugf("Request to %s: TLS handshake failed after %s: %s", t.addr, elapsed, err)
+		return
+	}
+	t.n.Log.Debugf("Request to %s: TLS handshake took %s", t.addr, elapsed)
+}
+
+func (t *requestTrace) gotConn(info httptrace.GotConnInfo) {
+	if info.Reused {
+		t.n.Log.Debugf("Request to %s: reusing connection to %s", t.addr, info.Conn.RemoteAddr())
+	}
+}
+
+func (t *requestTrace) gotFirstResponseByte() {
+	t.n.Log.Debugf("Request to %s: first byte after %s", t.addr, t.n.now().Sub(t.start))
+}
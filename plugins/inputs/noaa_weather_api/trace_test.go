@@ -0,0 +1,61 @@
+package noaa_weather_api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// debugLogger records the debug messages logged.
+type debugLogger struct {
+	testutil.Logger
+
+	sync.Mutex
+	messages []string
+}
+
+func (l *debugLogger) Debugf(format string, args ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestTraceRequests(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	for _, trace := range []bool{false, true} {
+		t.Run(fmt.Sprintf("trace=%v", trace), func(t *testing.T) {
+			logger := &debugLogger{}
+			n := &NOAAWeatherAPI{
+				BaseURL:       ts.URL,
+				StationID:     []string{"KSUA"},
+				TraceRequests: trace,
+				Log:           logger,
+			}
+			require.NoError(t, n.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, n.Gather(&acc))
+			require.Empty(t, acc.Errors)
+			require.Len(t, acc.Metrics, 1)
+
+			logger.Lock()
+			defer logger.Unlock()
+			if !trace {
+				require.Empty(t, logger.messages)
+				return
+			}
+			require.Len(t, logger.messages, 2)
+			require.True(t, strings.HasPrefix(logger.messages[0], "Request to "+ts.URL+"/stations/KSUA/observations/latest"))
+			require.Contains(t, logger.messages[0], "connecting to "+ts.Listener.Addr().String()+" took")
+			require.Contains(t, logger.messages[1], "first byte after")
+		})
+	}
+}
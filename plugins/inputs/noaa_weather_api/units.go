@@ -0,0 +1,53 @@
+package noaa_weather_api
+
+import (
+	"github.com/influxdata/telegraf"
+)
+
+// recordUnit remembers the unit code the field was reported with during the
+// current gather.
+func (n *NOAAWeatherAPI) recordUnit(field string, unitCode string) {
+	n.sourceUnitsLock.Lock()
+	defer n.sourceUnitsLock.Unlock()
+
+	n.sourceUnits[field] = unitCode
+}
+
+// addUnitMetadata reports the unit codes seen during the gather together
+// with the unit the values were converted to.
+func (n *NOAAWeatherAPI) addUnitMetadata(acc telegraf.Accumulator) {
+	n.sourceUnitsLock.Lock()
+	defer n.sourceUnitsLock.Unlock()
+
+	if len(n.sourceUnits) == 0 {
+		return
+	}
+
+	fields := make(map[string]interface{}, 2*len(n.sourceUnits))
+	for field, unitCode := range n.sourceUnits {
+		fields[field+"_source"] = unitCode
+		fields[field+"_target"] = n.targetUnit(field, unitCode)
+	}
+	acc.AddFields("noaa_weather_units", fields, nil, n.now())
+}
+
+// targetUnit returns the unit the value of the field is reported in, or the
+// unit code of the API for values reported unconverted.
+func (n *NOAAWeatherAPI) targetUnit(field string, unitCode string) string {
+	if n.Units == "none" || n.ServerSideUnits != "" {
+		return unitCode
+	}
+	if !n.overridden[unitCode] {
+		if !convertedFields[field] {
+			return unitCode
+		}
+		if field == "visibility" && n.VisibilityUnit != "" && unitCode == "wmoUnit:m" {
+			return n.VisibilityUnit
+		}
+	}
+
+	if c, ok := n.conversions[unitCode]; ok {
+		return c.target
+	}
+	return unitCode
+}
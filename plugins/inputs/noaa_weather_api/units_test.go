@@ -0,0 +1,54 @@
+package noaa_weather_api
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitUnitMetadata(t *testing.T) {
+	ts := newStationServer(t, map[string]string{
+		"/stations/KSUA/observations/latest": sampleStatusResponse,
+	})
+	defer ts.Close()
+
+	n := &NOAAWeatherAPI{
+		BaseURL:          ts.URL,
+		StationID:        []string{"KSUA"},
+		Units:            "imperial",
+		VisibilityUnit:   "km",
+		EmitUnitMetadata: true,
+	}
+	require.NoError(t, n.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, n.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+
+	m, ok := acc.Get("noaa_weather_units")
+	require.True(t, ok)
+	require.Empty(t, m.Tags)
+	require.Equal(t, map[string]interface{}{
+		"temperature_source":  "wmoUnit:degC",
+		"temperature_target":  "F",
+		"dewpoint_source":     "wmoUnit:degC",
+		"dewpoint_target":     "wmoUnit:degC",
+		"wind_speed_source":   "wmoUnit:km_h-1",
+		"wind_speed_target":   "mph",
+		"visibility_source":   "wmoUnit:m",
+		"visibility_target":   "km",
+		"humidity_source":     "wmoUnit:percent",
+		"humidity_target":     "wmoUnit:percent",
+		"pressure_source":     "wmoUnit:Pa",
+		"pressure_target":     "wmoUnit:Pa",
+		"wind_degrees_source": "wmoUnit:degree_(angle)",
+		"wind_degrees_target": "wmoUnit:degree_(angle)",
+	}, m.Fields)
+
+	// The metric is emitted once per gather
+	acc.ClearMetrics()
+	require.NoError(t, n.Gather(&acc))
+	require.Len(t, acc.Metrics, 2)
+}